@@ -1,6 +1,9 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type Configer interface {
 	String(key string) string
@@ -8,11 +11,15 @@ type Configer interface {
 	Int64(key string) (int64, error)
 	Bool(key string) (bool, error)
 	Float(key string) (float64, error)
+	Strings(key string) []string
+	Duration(key string) (time.Duration, error)
 	DefaultString(key string, defaultVal string) string
 	DefaultInt(key string, defaultVal int) int
 	DefaultInt64(key string, defaultVal int64) int64
 	DefaultBool(key string, defaultVal bool) bool
 	DefaultFloat(key string, defaultVal float64) float64
+	DefaultStrings(key string, defaultVal []string) []string
+	DefaultDuration(key string, defaultVal time.Duration) time.Duration
 	Set(key, value string) error
 }
 
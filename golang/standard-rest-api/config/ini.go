@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -171,7 +172,7 @@ func (ini *IniConfigerContainer) Int(key string) (int, error) {
 }
 
 func (ini *IniConfigerContainer) DefaultInt(key string, defaultval int) int {
-	v, err := ini.Int(ini.getData(key))
+	v, err := ini.Int(key)
 	if err != nil {
 		return defaultval
 	}
@@ -183,7 +184,7 @@ func (ini *IniConfigerContainer) Int64(key string) (int64, error) {
 }
 
 func (ini *IniConfigerContainer) DefaultInt64(key string, defaultval int64) int64 {
-	v, err := ini.Int64(ini.getData(key))
+	v, err := ini.Int64(key)
 	if err != nil {
 		return defaultval
 	}
@@ -214,6 +215,41 @@ func (ini *IniConfigerContainer) DefaultString(key string, defaultval string) st
 	return v
 }
 
+// Strings returns the []string value for a given key.
+// Values are separated by ";", e.g. origins = http://a.com;http://b.com
+func (ini *IniConfigerContainer) Strings(key string) []string {
+	v := ini.String(key)
+	if v == "" {
+		return nil
+	}
+	vals := strings.Split(v, ";")
+	for i := range vals {
+		vals[i] = strings.TrimSpace(vals[i])
+	}
+	return vals
+}
+
+func (ini *IniConfigerContainer) DefaultStrings(key string, defaultval []string) []string {
+	v := ini.Strings(key)
+	if v == nil {
+		return defaultval
+	}
+	return v
+}
+
+// Duration parses values like "5s" or "1m30s" with time.ParseDuration.
+func (ini *IniConfigerContainer) Duration(key string) (time.Duration, error) {
+	return time.ParseDuration(ini.getData(key))
+}
+
+func (ini *IniConfigerContainer) DefaultDuration(key string, defaultval time.Duration) time.Duration {
+	v, err := ini.Duration(key)
+	if err != nil {
+		return defaultval
+	}
+	return v
+}
+
 // section.key or key
 func (ini *IniConfigerContainer) getData(key string) string {
 	if len(key) == 0 {
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/render"
+)
+
+// renderCacheable renders v like render.Render with a strong ETag hashing
+// the encoded body, Last-Modified when lastModified is set and the
+// cacheControl header. It answers 304 Not Modified when the client's copy
+// is still current.
+func renderCacheable(w http.ResponseWriter, r *http.Request, v interface{}, lastModified time.Time, cacheControl string) {
+	c, body, err := render.Marshal(r, v)
+	if err == render.ErrNotAcceptable {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return
+	}
+	if err != nil {
+		log.Printf("%s %s: encode response error:%s", r.Method, r.URL.Path, err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Add("Vary", "Accept")
+	if cacheControl != "" {
+		h.Set("Cache-Control", cacheControl)
+	}
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", c.ContentType())
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when the former
+// is absent, as RFC 7232 section 6 asks.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// GET uses the weak comparison, W/ is ignored
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(since)
+}
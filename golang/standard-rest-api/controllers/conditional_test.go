@@ -0,0 +1,97 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestJobConditionalGet(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.register(t, "owner@example.com")
+	ts.createJob(t, token, "Gopher")
+
+	res := ts.do(t, "GET", "/job/1", "", nil)
+	expectStatus(t, res, http.StatusOK)
+	etag, lastModified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("ETag %q, Last-Modified %q, want both", etag, lastModified)
+	}
+	if cc := res.Header.Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Errorf("Cache-Control == %q, want \"public, max-age=60\"", cc)
+	}
+
+	cases := []struct {
+		header []string
+		want   int
+	}{
+		{[]string{"If-None-Match", etag}, http.StatusNotModified},
+		{[]string{"If-None-Match", "W/" + etag}, http.StatusNotModified},
+		{[]string{"If-None-Match", `"other", ` + etag}, http.StatusNotModified},
+		{[]string{"If-None-Match", "*"}, http.StatusNotModified},
+		{[]string{"If-None-Match", `"other"`}, http.StatusOK},
+		{[]string{"If-Modified-Since", lastModified}, http.StatusNotModified},
+		{[]string{"If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, http.StatusNotModified},
+		{[]string{"If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}, http.StatusOK},
+		// If-None-Match wins over If-Modified-Since
+		{[]string{"If-None-Match", `"other"`, "If-Modified-Since", lastModified}, http.StatusOK},
+	}
+	for _, c := range cases {
+		res := ts.do(t, "GET", "/job/1", "", nil, c.header...)
+		if res.StatusCode != c.want {
+			t.Errorf("GET /job/1 with %q == %d, want %d", c.header, res.StatusCode, c.want)
+		}
+		if res.StatusCode == http.StatusNotModified && res.Header.Get("ETag") != etag {
+			t.Errorf("304 with %q has ETag %q, want %q", c.header, res.Header.Get("ETag"), etag)
+		}
+	}
+
+	// An update changes the ETag, the old one gets the new body
+	res = ts.do(t, "PUT", "/job/1", token, map[string]string{"title": "Gopher 2", "description": "Updated"})
+	expectStatus(t, res, http.StatusOK)
+	res = ts.do(t, "GET", "/job/1", "", nil, "If-None-Match", etag)
+	expectStatus(t, res, http.StatusOK)
+	if res.Header.Get("ETag") == etag {
+		t.Error("the ETag didn't change with the job")
+	}
+}
+
+func TestJobETagVariesWithAccept(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.register(t, "owner@example.com")
+	ts.createJob(t, token, "Gopher")
+
+	res := ts.do(t, "GET", "/job/1", "", nil)
+	etag := res.Header.Get("ETag")
+	res = ts.do(t, "GET", "/job/1", "", nil, "Accept", "application/xml", "If-None-Match", etag)
+	expectStatus(t, res, http.StatusOK)
+	if res.Header.Get("ETag") == etag {
+		t.Error("the JSON and XML bodies share an ETag")
+	}
+	if vary := res.Header.Values("Vary"); len(vary) == 0 {
+		t.Error("the response doesn't vary on Accept")
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.register(t, "owner@example.com")
+	ts.createJob(t, token, "Gopher")
+
+	res := ts.do(t, "GET", "/feed", "", nil)
+	expectStatus(t, res, http.StatusOK)
+	etag := res.Header.Get("ETag")
+	if cc := res.Header.Get("Cache-Control"); cc != "public, max-age=30" {
+		t.Errorf("Cache-Control == %q, want \"public, max-age=30\"", cc)
+	}
+	if lm := res.Header.Get("Last-Modified"); lm != "" {
+		t.Errorf("the feed has Last-Modified %q", lm)
+	}
+	res = ts.do(t, "GET", "/feed", "", nil, "If-None-Match", etag)
+	expectStatus(t, res, http.StatusNotModified)
+
+	// A new job changes the feed
+	ts.createJob(t, token, "Gopher 2")
+	res = ts.do(t, "GET", "/feed", "", nil, "If-None-Match", etag)
+	expectStatus(t, res, http.StatusOK)
+}
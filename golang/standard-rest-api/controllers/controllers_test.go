@@ -0,0 +1,118 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/standard-rest-api/controllers"
+	"github.com/golang/standard-rest-api/repositories"
+	"github.com/golang/standard-rest-api/routers"
+	"github.com/golang/standard-rest-api/utils/caching"
+)
+
+// testServer serves the routes of main over the in-memory repositories
+// and cache, with the job cache in front like in production.
+type testServer struct {
+	*httptest.Server
+	Users *repositories.MemoryUserRepository
+	Jobs  *repositories.MemoryJobRepository
+	Cache *caching.Memory
+	JC    *controllers.JobController
+}
+
+func newTestServer(t *testing.T) *testServer {
+	users := repositories.NewMemoryUserRepository()
+	jobs := repositories.NewMemoryJobRepository(users)
+	cache := caching.NewMemory(0, 0)
+	loading := caching.NewLoadingCache(cache, time.Minute, repositories.ErrNotFound, 10*time.Second)
+	cached := repositories.NewCachedJobRepository(jobs, loading, time.Minute, time.Minute)
+
+	uc := controllers.NewUserController(users, cache)
+	jc := controllers.NewJobController(cached, cache)
+	jc.JobCacheControl = "public, max-age=60"
+	jc.FeedCacheControl = "public, max-age=30"
+	hc := controllers.NewHealthController(nil, cache, time.Second)
+
+	mux := http.NewServeMux()
+	routers.CreateRouters(mux, uc, jc, hc)
+	ts := &testServer{Server: httptest.NewServer(mux), Users: users, Jobs: jobs, Cache: cache, JC: jc}
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// do sends body as JSON with the token and header pairs
+func (ts *testServer) do(t *testing.T, method, path, token string, body interface{}, header ...string) *http.Response {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = strings.NewReader(string(b))
+	}
+	req, err := http.NewRequest(method, ts.URL+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("token", token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+// decode reads the JSON body of res into v
+func decode(t *testing.T, res *http.Response, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatalf("decode %s %s response: %s", res.Request.Method, res.Request.URL.Path, err)
+	}
+}
+
+func expectStatus(t *testing.T, res *http.Response, status int) {
+	t.Helper()
+	if res.StatusCode != status {
+		body, _ := io.ReadAll(res.Body)
+		t.Fatalf("%s %s == %d %q, want %d", res.Request.Method, res.Request.URL.Path, res.StatusCode, body, status)
+	}
+}
+
+// register creates a user and returns its token
+func (ts *testServer) register(t *testing.T, email string) string {
+	t.Helper()
+	res := ts.do(t, "POST", "/register", "", map[string]string{
+		"email": email, "name": "Test", "password": "secret",
+	})
+	expectStatus(t, res, http.StatusOK)
+	var tr struct {
+		Token string `json:"token"`
+	}
+	decode(t, res, &tr)
+	return tr.Token
+}
+
+// createJob creates a job as the owner of token, the memory repository
+// numbers the jobs from 1.
+func (ts *testServer) createJob(t *testing.T, token, title string) {
+	t.Helper()
+	res := ts.do(t, "POST", "/job", token, map[string]string{
+		"title": title, "description": "Description of " + title,
+	})
+	expectStatus(t, res, http.StatusCreated)
+}
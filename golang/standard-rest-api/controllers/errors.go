@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/golang/standard-rest-api/repositories"
+	"github.com/golang/standard-rest-api/utils/caching"
+)
+
+// statusClientClosedRequest is the non-standard code nginx logs when the
+// client went away before the response was written.
+const statusClientClosedRequest = 499
+
+// repositoryError answers with the status matching a repository error
+func repositoryError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		http.Error(w, "Not Found", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrConflict):
+		http.Error(w, "Conflict", http.StatusConflict)
+	case errors.Is(err, repositories.ErrInvalidReference):
+		http.Error(w, "Unprocessable Entity", http.StatusUnprocessableEntity)
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("%s %s: query timed out", r.Method, r.URL.Path)
+		http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+	case errors.Is(err, context.Canceled):
+		// Nobody is listening anymore, the status only ends up in the metrics
+		w.WriteHeader(statusClientClosedRequest)
+	default:
+		log.Printf("%s %s: repository error:%s", r.Method, r.URL.Path, err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// tokenError answers a failed token lookup, an unknown token is forbidden
+// while an unreachable cache is reported as such.
+func tokenError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, caching.ErrMiss) {
+		http.Error(w, "Invalid token", http.StatusForbidden)
+		return
+	}
+	cacheError(w, r, "token lookup", err)
+}
+
+// cacheError answers a failed cache operation, the cache being down is
+// temporary and must not take the process with it.
+func cacheError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	log.Printf("%s %s: %s error:%s", r.Method, r.URL.Path, op, err)
+	http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+}
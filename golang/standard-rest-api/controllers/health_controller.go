@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/caching"
+	"github.com/golang/standard-rest-api/utils/render"
+)
+
+type HealthController struct {
+	DB      *sql.DB
+	Cache   caching.Cache
+	Timeout time.Duration
+	ready   int32
+}
+
+type healthStatus struct {
+	Status string `json:"status" xml:"status"`
+}
+
+type dependencyStatus struct {
+	Name   string `json:"name" xml:"name"`
+	Status string `json:"status" xml:"status"`
+	Error  string `json:"error,omitempty" xml:"error,omitempty"`
+	Took   string `json:"took" xml:"took"`
+}
+
+type readinessStatus struct {
+	Status       string              `json:"status" xml:"status"`
+	Dependencies []*dependencyStatus `json:"dependencies" xml:"dependency"`
+}
+
+func NewHealthController(db *sql.DB, c caching.Cache, timeout time.Duration) *HealthController {
+	return &HealthController{
+		DB:      db,
+		Cache:   c,
+		Timeout: timeout,
+		ready:   1,
+	}
+}
+
+// SetReady flips the readiness reported by /readyz, it is turned off
+// at the start of a graceful shutdown so no new traffic gets routed here.
+func (hc *HealthController) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&hc.ready, v)
+}
+
+func (hc *HealthController) IsReady() bool {
+	return atomic.LoadInt32(&hc.ready) == 1
+}
+
+// Healthz only reports that the process is alive and serving HTTP
+func (hc *HealthController) Healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	render.Render(w, r, http.StatusOK, &healthStatus{Status: "ok"})
+}
+
+// Readyz pings every backend the API depends on, DB is nil when the
+// repositories aren't backed by a database.
+func (hc *HealthController) Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), hc.Timeout)
+	defer cancel()
+
+	rs := readinessStatus{
+		Status: "ok",
+	}
+	if hc.DB != nil {
+		rs.Dependencies = append(rs.Dependencies, check(ctx, "database", hc.DB.PingContext))
+	}
+	rs.Dependencies = append(rs.Dependencies, check(ctx, "cache", func(context.Context) error {
+		return hc.Cache.Ping()
+	}))
+	status := http.StatusOK
+	for _, d := range rs.Dependencies {
+		if d.Status != "ok" {
+			rs.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	if !hc.IsReady() {
+		rs.Status = "shutting down"
+		status = http.StatusServiceUnavailable
+	}
+
+	render.Render(w, r, status, &rs)
+}
+
+// check runs ping in its own goroutine so a backend without context
+// support can't hold the probe longer than the deadline of ctx.
+func check(ctx context.Context, name string, ping func(context.Context) error) *dependencyStatus {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- ping(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	ds := &dependencyStatus{
+		Name:   name,
+		Status: "ok",
+		Took:   time.Since(start).String(),
+	}
+	if err != nil {
+		ds.Status = "down"
+		ds.Error = err.Error()
+	}
+	return ds
+}
@@ -1,58 +1,70 @@
 package controllers
 
 import (
-	"database/sql"
 	"github.com/golang/standard-rest-api/utils/caching"
 	"net/http"
-	"fmt"
+	"errors"
 	"strconv"
 	"log"
-	"encoding/json"
 	"github.com/golang/standard-rest-api/requests"
 	"github.com/golang/standard-rest-api/repositories"
 	"path"
+	"github.com/golang/standard-rest-api/utils/database"
+	"time"
 )
 
 type JobController struct {
-	DB *sql.DB
+	Jobs repositories.JobRepository
 	Cache caching.Cache
+	// Cache-Control of the job and feed responses, empty leaves it out
+	JobCacheControl string
+	FeedCacheControl string
+	// LegacyTokens holds the session tokens written before the keys were
+	// namespaced, nil once they have all expired
+	LegacyTokens caching.Cache
 }
 
-func NewJobController(db *sql.DB, c caching.Cache) *JobController {
+func NewJobController(jobs repositories.JobRepository, c caching.Cache) *JobController {
 	return &JobController{
-		DB: db,
+		Jobs: jobs,
 		Cache: c,
 	}
 }
 
+// userID returns the user id of the session token, falling back to the
+// legacy "token_" key so that existing sessions survive the deploy
+func (jc *JobController) userID(token string) (string, error) {
+	id, err := jc.Cache.Get(tokenKey(token))
+	if errors.Is(err, caching.ErrMiss) && jc.LegacyTokens != nil {
+		return jc.LegacyTokens.Get("token_" + token)
+	}
+	return id, err
+}
+
 func (jc *JobController) Create(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
 	token := r.Header.Get("token")
-	userIDStr, err := jc.Cache.Get(fmt.Sprintf("token_%s", token))
+	userIDStr, err := jc.userID(token)
 	if err != nil {
-		http.Error(w, "Invalid token", http.StatusForbidden)
+		tokenError(w, r, err)
 		return
 	}
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		log.Fatalf("Convert user id to int:%s", userID)
+		log.Fatalf("Convert user id to int:%s", err)
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
-	decoder := json.NewDecoder(r.Body)
 	var cjr requests.CreateJobRequest
-	err = decoder.Decode(&cjr)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !bind(w, r, &cjr) {
 		return
 	}
-	_, err = repositories.CreateJob(jc.DB, cjr.Title, cjr.Description, userID)
+	_, err = jc.Jobs.CreateJob(r.Context(), cjr.Title, cjr.Description, userID)
 	if err != nil {
-		log.Fatalf("Create a job error:%s", err)
-		http.Error(w, "", http.StatusInternalServerError)
+		repositoryError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -68,19 +80,25 @@ func (jc *JobController) Job(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
-	job, err := repositories.GetJobByID(jc.DB, jobID)
+	ctx := r.Context()
+	if r.Method != "GET" {
+		// Check the owner against the primary, bypassing the cache, before
+		// mutating the job
+		ctx = database.WithPrimary(ctx)
+	}
+	job, err := jc.Jobs.GetJobByID(ctx, jobID)
 	if err != nil {
-		http.Error(w, "Not Found", http.StatusNotFound)
+		repositoryError(w, r, err)
 		return
 	}
 	if r.Method == "GET" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(job)
+		renderCacheable(w, r, job, job.UpdatedAt, jc.JobCacheControl)
+		return
 	}
 	token := r.Header.Get("token")
-	userIDStr, err := jc.Cache.Get(fmt.Sprintf("token_%s", token))
+	userIDStr, err := jc.userID(token)
 	if err != nil {
-		http.Error(w, "Invalid token", http.StatusForbidden)
+		tokenError(w, r, err)
 		return
 	}
 	_, err = strconv.Atoi(userIDStr)
@@ -95,59 +113,62 @@ func (jc *JobController) Job(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == "PUT" {
-		decoder := json.NewDecoder(r.Body)
 		var ujr requests.UpdateJobRequest
-		err := decoder.Decode(&ujr)
-		if err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !bind(w, r, &ujr) {
 			return
 		}
-		err = repositories.UpdateJob(jc.DB, job.ID, ujr.Title, ujr.Description)
+		err = jc.Jobs.UpdateJob(r.Context(), job.ID, ujr.Title, ujr.Description)
 		if err != nil {
-			log.Fatalf("Updating a job:%s", err)
-			http.Error(w, "", http.StatusInternalServerError)
+			repositoryError(w, r, err)
 			return
 		}
 	}
 
 	if r.Method == "DELETE" {
-		err = repositories.DeleteJob(jc.DB, job.ID)
+		err = jc.Jobs.DeleteJob(r.Context(), job.ID)
 		if err != nil {
-			http.Error(w, "", http.StatusInternalServerError)
+			repositoryError(w, r, err)
 			return
 		}
 	}
 }
 
 func (jc *JobController) Feed(w http.ResponseWriter, r *http.Request) {
-	var err error
 	if r.Method != "GET" {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
-	page := 1
-	pageStr, ok := r.URL.Query()["page"]
-	if ok {
-		page, err = strconv.Atoi(pageStr[0])
-		if err != nil {
-			page = 1
-		}
+	page, ok := positiveParam(r, "page", 1)
+	if !ok {
+		http.Error(w, "Invalid page", http.StatusBadRequest)
+		return
 	}
-
-	resultsPerPage := 10
-	resultsPerPageStr, ok := r.URL.Query()["results_per_page"]
-	if ok {
-		resultsPerPage, err = strconv.Atoi(resultsPerPageStr[0])
-		if err != nil {
-			resultsPerPage = 10
-		}
+	resultsPerPage, ok := positiveParam(r, "results_per_page", 10)
+	if !ok {
+		http.Error(w, "Invalid results_per_page", http.StatusBadRequest)
+		return
 	}
 
-	jobs, err := repositories.GetJobs(jc.DB, page, resultsPerPage)
-	w.Header().Set("Content-Type", "application/json")
+	jobs, err := jc.Jobs.GetJobs(r.Context(), page, resultsPerPage)
 	if err != nil {
-		http.Error(w, "", http.StatusInternalServerError)
+		repositoryError(w, r, err)
 		return
 	}
-	json.NewEncoder(w).Encode(jobs)
-}
\ No newline at end of file
+	// No Last-Modified, a deleted job shifts the pages without changing
+	// any updated_at
+	renderCacheable(w, r, jobs, time.Time{}, jc.FeedCacheControl)
+}
+// positiveParam returns the query parameter name of r, or def when it is
+// absent. ok is false when it isn't a number of at least 1, the SQL
+// repository would otherwise run the query with a negative OFFSET or LIMIT.
+func positiveParam(r *http.Request, name string, def int) (n int, ok bool) {
+	values, present := r.URL.Query()[name]
+	if !present {
+		return def, true
+	}
+	n, err := strconv.Atoi(values[0])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,158 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/golang/standard-rest-api/models"
+	"github.com/golang/standard-rest-api/utils/caching"
+)
+
+func TestJobLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.register(t, "owner@example.com")
+	ts.createJob(t, token, "Gopher")
+
+	res := ts.do(t, "GET", "/job/1", "", nil)
+	expectStatus(t, res, http.StatusOK)
+	var job models.Job
+	decode(t, res, &job)
+	if job.ID != 1 || job.Title != "Gopher" || job.UserID != "1" {
+		t.Errorf("GET /job/1 == %+v", job)
+	}
+
+	// The cached job is invalidated by the update
+	res = ts.do(t, "PUT", "/job/1", token, map[string]string{"title": "Gopher 2", "description": "Updated"})
+	expectStatus(t, res, http.StatusOK)
+	res = ts.do(t, "GET", "/job/1", "", nil)
+	decode(t, res, &job)
+	if job.Title != "Gopher 2" {
+		t.Errorf("title after the update == %q, want \"Gopher 2\"", job.Title)
+	}
+
+	res = ts.do(t, "GET", "/feed", "", nil)
+	var feed []models.Job
+	decode(t, res, &feed)
+	if len(feed) != 1 || feed[0].Title != "Gopher 2" {
+		t.Errorf("feed == %+v, want the updated job", feed)
+	}
+
+	res = ts.do(t, "DELETE", "/job/1", token, nil)
+	expectStatus(t, res, http.StatusOK)
+	res = ts.do(t, "GET", "/job/1", "", nil)
+	expectStatus(t, res, http.StatusNotFound)
+	res = ts.do(t, "GET", "/feed", "", nil)
+	decode(t, res, &feed)
+	if len(feed) != 0 {
+		t.Errorf("feed == %+v after the delete, want none", feed)
+	}
+}
+
+func TestJobRequiresOwner(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.register(t, "owner@example.com")
+	other := ts.register(t, "other@example.com")
+	ts.createJob(t, owner, "Gopher")
+
+	for _, method := range []string{"PUT", "DELETE"} {
+		res := ts.do(t, method, "/job/1", other, map[string]string{"title": "Stolen"})
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s /job/1 by another user == %d, want 401", method, res.StatusCode)
+		}
+	}
+	res := ts.do(t, "GET", "/job/1", "", nil)
+	var job models.Job
+	decode(t, res, &job)
+	if job.Title != "Gopher" {
+		t.Errorf("title == %q after the rejected update", job.Title)
+	}
+}
+
+func TestJobInvalidToken(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.register(t, "owner@example.com")
+	ts.createJob(t, owner, "Gopher")
+
+	for _, token := range []string{"", "unknown"} {
+		res := ts.do(t, "POST", "/job", token, map[string]string{"title": "Gopher"})
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("POST /job with token %q == %d, want 403", token, res.StatusCode)
+		}
+		res = ts.do(t, "DELETE", "/job/1", token, nil)
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("DELETE /job/1 with token %q == %d, want 403", token, res.StatusCode)
+		}
+	}
+}
+
+func TestJobOfUnknownUser(t *testing.T) {
+	ts := newTestServer(t)
+	// A session outliving its user
+	ts.Cache.Set("token:orphan", "42", caching.NoExpiration)
+
+	res := ts.do(t, "POST", "/job", "orphan", map[string]string{"title": "Gopher"})
+	expectStatus(t, res, http.StatusUnprocessableEntity)
+}
+
+func TestJobLegacyToken(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register(t, "owner@example.com")
+	legacy := caching.NewMemory(0, 0)
+	legacy.Set("token_old", "1", caching.NoExpiration)
+
+	res := ts.do(t, "POST", "/job", "old", map[string]string{"title": "Gopher"})
+	expectStatus(t, res, http.StatusForbidden)
+
+	ts.JC.LegacyTokens = legacy
+	ts.createJob(t, "old", "Gopher")
+}
+
+func TestJobNotFound(t *testing.T) {
+	ts := newTestServer(t)
+	for _, path := range []string{"/job/1", "/job/abc"} {
+		res := ts.do(t, "GET", path, "", nil)
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s == %d, want 404", path, res.StatusCode)
+		}
+	}
+}
+
+func TestFeedPagination(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.register(t, "gopher@example.com")
+	for _, title := range []string{"One", "Two", "Three"} {
+		ts.createJob(t, token, title)
+	}
+
+	cases := []struct {
+		query  string
+		status int
+		jobs   int
+	}{
+		{"", http.StatusOK, 3},
+		{"?page=2&results_per_page=2", http.StatusOK, 1},
+		{"?page=3&results_per_page=2", http.StatusOK, 0},
+		{"?page=0", http.StatusBadRequest, 0},
+		{"?page=-1", http.StatusBadRequest, 0},
+		{"?page=abc", http.StatusBadRequest, 0},
+		{"?page=", http.StatusBadRequest, 0},
+		{"?results_per_page=0", http.StatusBadRequest, 0},
+		{"?results_per_page=-5", http.StatusBadRequest, 0},
+		{"?page=2&results_per_page=ten", http.StatusBadRequest, 0},
+	}
+	for _, c := range cases {
+		res := ts.do(t, "GET", "/feed"+c.query, "", nil)
+		if res.StatusCode != c.status {
+			t.Errorf("GET /feed%s == %d, want %d", c.query, res.StatusCode, c.status)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		var jobs []map[string]interface{}
+		decode(t, res, &jobs)
+		if len(jobs) != c.jobs {
+			t.Errorf("GET /feed%s returned %d jobs, want %d", c.query, len(jobs), c.jobs)
+		}
+	}
+}
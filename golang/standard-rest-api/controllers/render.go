@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/golang/standard-rest-api/utils/render"
+)
+
+// bind decodes the request body into v, it answers 415 or 400 and
+// returns false when the body can't be decoded.
+func bind(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := render.Bind(r, v)
+	if err == nil {
+		return true
+	}
+	if err == render.ErrUnsupportedMediaType {
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return false
+	}
+	http.Error(w, "Invalid request body", http.StatusBadRequest)
+	return false
+}
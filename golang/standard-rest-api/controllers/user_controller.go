@@ -1,71 +1,81 @@
 package controllers
 
 import (
-	"database/sql"
 	"github.com/golang/standard-rest-api/utils/caching"
 	"net/http"
-	"encoding/json"
+	"errors"
 	"github.com/golang/standard-rest-api/requests"
+	"github.com/golang/standard-rest-api/responses"
 	"github.com/golang/standard-rest-api/repositories"
 	"log"
 	"github.com/golang/standard-rest-api/utils/crypto"
 	"time"
-	"fmt"
 	"strconv"
+	"github.com/golang/standard-rest-api/utils/render"
 )
 
 type UserController struct {
-	DB *sql.DB
+	Users repositories.UserRepository
 	Cache caching.Cache
 }
 
-func NewUserController(db *sql.DB, c caching.Cache) *UserController {
+func NewUserController(users repositories.UserRepository, c caching.Cache) *UserController {
 	return &UserController{
-		DB: db,
+		Users: users,
 		Cache: c,
 	}
 }
 
+// tokenKey is the cache key holding the user id of a session token
+func tokenKey(token string) string {
+	return "token:" + token
+}
+
+// sessionTTL is how long a token stays valid
+const sessionTTL = 30 * 24 * time.Hour
+
+// newSession stores a new token of the user, it answers the request itself
+// and returns false when that fails.
+func (uc *UserController) newSession(w http.ResponseWriter, r *http.Request, userID int) (string, bool) {
+	token, err := crypto.GenerateToken()
+	if err != nil {
+		log.Printf("%s %s: generate token error:%s", r.Method, r.URL.Path, err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return "", false
+	}
+	if err := uc.Cache.Set(tokenKey(token), strconv.Itoa(userID), sessionTTL); err != nil {
+		cacheError(w, r, "store token", err)
+		return "", false
+	}
+	return token, true
+}
+
 func (uc *UserController) Register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
-	decoder := json.NewDecoder(r.Body)
 	var rr requests.RegisterRequest
-	err := decoder.Decode(&rr)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !bind(w, r, &rr) {
 		return
 	}
 
-	id, err := repositories.CreateUser(uc.DB, rr.Email, rr.Name, rr.Password)
+	id, err := uc.Users.CreateUser(r.Context(), rr.Email, rr.Name, rr.Password)
 	if err != nil {
-		log.Fatalf("Add user to database error:%s", err)
-		http.Error(w, "", http.StatusInternalServerError)
-		return
-	}
-
-	token, err := crypto.GenerateToken()
-	if err != nil {
-		log.Fatalf("Generate token error:%s", err)
-		http.Error(w, "", http.StatusInternalServerError)
+		if errors.Is(err, repositories.ErrConflict) {
+			http.Error(w, "Email is already registered", http.StatusConflict)
+			return
+		}
+		repositoryError(w, r, err)
 		return
 	}
 
-	oneMonth := time.Duration(60*60*24*30) * time.Second
-	err = uc.Cache.Set(fmt.Sprintf("token_%s", token), strconv.Itoa(id), oneMonth)
-	if err != nil {
-		log.Fatalf("Add token to redis error:%s", err)
-		http.Error(w, "", http.StatusInternalServerError)
+	token, ok := uc.newSession(w, r, id)
+	if !ok {
 		return
 	}
 
-	p := map[string]string {
-		"Token": token,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(p)
+	render.Render(w, r, http.StatusOK, &responses.RegisterResponse{Token: token})
 }
 
 func (uc *UserController) Login(w http.ResponseWriter, r *http.Request) {
@@ -73,47 +83,37 @@ func (uc *UserController) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
-	decoder := json.NewDecoder(r.Body)
 	var lr requests.LoginRequest
-	err := decoder.Decode(lr)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !bind(w, r, &lr) {
 		return
 	}
-	user, err := repositories.GetPrivateUserDetailByEmail(uc.DB, lr.Email)
+	user, err := uc.Users.GetPrivateUserDetailByEmail(r.Context(), lr.Email)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repositories.ErrNotFound) {
 			http.Error(w, "Invalid username or password", http.StatusBadRequest)
 			return
 		}
-		log.Fatal("Create user error:%s", err)
-		http.Error(w, "", http.StatusInternalServerError)
+		repositoryError(w, r, err)
 		return
 	}
 
-	password := crypto.HashPassword(lr.Password, user.Salt)
-	if user.Password != password {
+	ok, rehash := crypto.VerifyPassword(lr.Password, user.Salt, user.Password)
+	if !ok {
 		http.Error(w, "Invalid username or password", http.StatusBadRequest)
 		return
 	}
-
-	token, err := crypto.GenerateToken()
-	if err != nil {
-		log.Fatal("Create user error:%s", err)
-		http.Error(w, "", http.StatusInternalServerError)
-		return
+	if rehash {
+		// Upgrade a hash stored with the legacy encoding, the login goes on
+		// if it fails and the next one retries
+		if err := uc.Users.UpdatePassword(r.Context(), user.ID, lr.Password); err != nil {
+			log.Printf("Rehash password of user %d error:%s", user.ID, err)
+		}
 	}
-	oneMonth := time.Duration(60*60*24*30) * time.Second
-	err = uc.Cache.Set(fmt.Sprintf("token_%s", token), strconv.Itoa(user.ID), oneMonth)
-	if err != nil {
-		log.Fatalf("Create user error:%s", err)
-		http.Error(w, "", http.StatusInternalServerError)
+
+	token, ok := uc.newSession(w, r, user.ID)
+	if !ok {
 		return
 	}
 
-	p := map[string]string {
-		"token": token,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(p)
+	render.Render(w, r, http.StatusOK, &responses.TokenResponse{Token: token})
 }
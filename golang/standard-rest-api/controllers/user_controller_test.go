@@ -0,0 +1,112 @@
+package controllers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/standard-rest-api/controllers"
+	"github.com/golang/standard-rest-api/repositories"
+	"github.com/golang/standard-rest-api/utils/caching"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	ts := newTestServer(t)
+	if ts.register(t, "gopher@example.com") == "" {
+		t.Fatal("register returned an empty token")
+	}
+
+	res := ts.do(t, "POST", "/register", "", map[string]string{
+		"email": "gopher@example.com", "name": "Other", "password": "other",
+	})
+	expectStatus(t, res, http.StatusConflict)
+
+	res = ts.do(t, "POST", "/login", "", map[string]string{
+		"email": "gopher@example.com", "password": "secret",
+	})
+	expectStatus(t, res, http.StatusOK)
+	var tr struct {
+		Token string `json:"token"`
+	}
+	decode(t, res, &tr)
+
+	// The token of the login authenticates like the one of the register
+	ts.createJob(t, tr.Token, "Gopher")
+}
+
+// TestTokenKeys pins the keys clients read the token from, /register has
+// always sent "Token" and /login "token"
+func TestTokenKeys(t *testing.T) {
+	ts := newTestServer(t)
+	creds := map[string]string{"email": "gopher@example.com", "name": "Test", "password": "secret"}
+	// In order, the login needs the user
+	cases := []struct {
+		path, key string
+	}{
+		{"/register", "Token"},
+		{"/login", "token"},
+	}
+	for _, c := range cases {
+		res := ts.do(t, "POST", c.path, "", creds)
+		expectStatus(t, res, http.StatusOK)
+		var body map[string]string
+		decode(t, res, &body)
+		if body[c.key] == "" || len(body) != 1 {
+			t.Errorf("POST %s == %v, want the token under %q", c.path, body, c.key)
+		}
+	}
+}
+
+func TestLoginRejected(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register(t, "gopher@example.com")
+
+	cases := []struct {
+		email, password string
+	}{
+		{"gopher@example.com", "wrong"},
+		{"nobody@example.com", "secret"},
+	}
+	for _, c := range cases {
+		res := ts.do(t, "POST", "/login", "", map[string]string{"email": c.email, "password": c.password})
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("login of %s with %q == %d, want 400", c.email, c.password, res.StatusCode)
+		}
+	}
+}
+
+func TestUserRoutesOnlyAcceptPost(t *testing.T) {
+	ts := newTestServer(t)
+	for _, path := range []string{"/register", "/login"} {
+		res := ts.do(t, "GET", path, "", nil)
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s == %d, want 404", path, res.StatusCode)
+		}
+	}
+}
+
+// downCache fails every write like an unreachable redis
+type downCache struct {
+	caching.Cache
+}
+
+func (downCache) Set(key, value string, expiration time.Duration) error {
+	return errors.New("dial tcp: connection refused")
+}
+
+func TestSessionCacheDown(t *testing.T) {
+	uc := controllers.NewUserController(repositories.NewMemoryUserRepository(), downCache{caching.NewMemory(0, 0)})
+	body := `{"email":"gopher@example.com","name":"Test","password":"secret"}`
+	for _, handler := range []http.HandlerFunc{uc.Register, uc.Login} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("status with the cache down == %d, want 503", w.Code)
+		}
+	}
+}
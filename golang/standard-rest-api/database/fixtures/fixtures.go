@@ -0,0 +1,122 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/golang/standard-rest-api/repositories"
+	"gopkg.in/yaml.v2"
+)
+
+// File is the content of a fixture file, jobs refer to their owner by the
+// Ref of a user of the same file or of a file loaded before, e.g.
+//
+//	users:
+//	  - ref: alice
+//	    email: alice@example.com
+//	    name: Alice
+//	    password: secret
+//	jobs:
+//	  - title: Backend Engineer
+//	    description: Go and PostgreSQL
+//	    user: alice
+type File struct {
+	Users []User `json:"users" yaml:"users"`
+	Jobs  []Job  `json:"jobs" yaml:"jobs"`
+}
+
+type User struct {
+	Ref      string `json:"ref" yaml:"ref"`
+	Email    string `json:"email" yaml:"email"`
+	Name     string `json:"name" yaml:"name"`
+	Password string `json:"password" yaml:"password"`
+}
+
+type Job struct {
+	Ref         string `json:"ref" yaml:"ref"`
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description" yaml:"description"`
+	User        string `json:"user" yaml:"user"`
+}
+
+// Loader writes fixtures through the repositories and remembers the ids
+// of the records by ref, so tests can look them up.
+type Loader struct {
+	Users   repositories.UserRepository
+	Jobs    repositories.JobRepository
+	UserIDs map[string]int
+	JobIDs  map[string]int
+}
+
+func NewLoader(users repositories.UserRepository, jobs repositories.JobRepository) *Loader {
+	return &Loader{
+		Users:   users,
+		Jobs:    jobs,
+		UserIDs: make(map[string]int),
+		JobIDs:  make(map[string]int),
+	}
+}
+
+// LoadFiles loads .yaml, .yml and .json files in order
+func (l *Loader) LoadFiles(ctx context.Context, paths ...string) error {
+	for _, path := range paths {
+		f, err := ParseFile(path)
+		if err != nil {
+			return err
+		}
+		if err := l.Load(ctx, f); err != nil {
+			return fmt.Errorf("fixtures: %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// ParseFile decodes a fixture file, the format comes from its extension
+func ParseFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &f)
+	case ".json":
+		err = json.Unmarshal(data, &f)
+	default:
+		return nil, fmt.Errorf("fixtures: unsupported file %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fixtures: parse %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+func (l *Loader) Load(ctx context.Context, f *File) error {
+	for _, u := range f.Users {
+		id, err := l.Users.CreateUser(ctx, u.Email, u.Name, u.Password)
+		if err != nil {
+			return fmt.Errorf("create user %s: %w", u.Email, err)
+		}
+		if u.Ref != "" {
+			l.UserIDs[u.Ref] = id
+		}
+	}
+	for _, j := range f.Jobs {
+		userID, ok := l.UserIDs[j.User]
+		if !ok {
+			return fmt.Errorf("job %q refers to unknown user %q", j.Title, j.User)
+		}
+		id, err := l.Jobs.CreateJob(ctx, j.Title, j.Description, userID)
+		if err != nil {
+			return fmt.Errorf("create job %q: %w", j.Title, err)
+		}
+		if j.Ref != "" {
+			l.JobIDs[j.Ref] = id
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/database"
+)
+
+// files holds one directory of migrations per dialect, named after the
+// database.Dialect, they must use the same versions.
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// lockID is the key of the advisory lock held while migrating so
+// instances starting at the same time apply migrations one at a time.
+const lockID = 7263590418
+
+// fileName matches 0001_create_users.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    database.Dialect
+	Migrations []*Migration
+}
+
+// NewMigrator loads the migrations of dialect embedded in the binary
+func NewMigrator(db *sql.DB, dialect database.Dialect) (*Migrator, error) {
+	dir, err := fs.Sub(files, dialect.Name())
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		DB:         db,
+		Dialect:    dialect,
+		Migrations: migrations,
+	}, nil
+}
+
+func load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := fs.ReadFile(fsys, path.Join(".", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrations: version %d is used by %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migrations: %04d_%s has no up migration", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Latest is the version of the newest embedded migration
+func (m *Migrator) Latest() int {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the latest applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return nil
+		}
+		target := 0
+		for _, mig := range m.Migrations {
+			if mig.Version < current {
+				target = mig.Version
+			}
+		}
+		return m.migrate(ctx, conn, current, target)
+	})
+}
+
+// To migrates up or down until version is the latest applied migration
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("migrations: unknown version %d", version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+// Status lists every embedded migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	var statuses []*Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(ctx, `select version, applied_at from schema_migrations`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		applied := make(map[int]time.Time)
+		for rows.Next() {
+			var (
+				version   int
+				appliedAt time.Time
+			)
+			if err := rows.Scan(&version, &appliedAt); err != nil {
+				return err
+			}
+			applied[version] = appliedAt
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, mig := range m.Migrations {
+			s := &Status{Version: mig.Version, Name: mig.Name}
+			if t, ok := applied[mig.Version]; ok {
+				s.AppliedAt = &t
+			}
+			statuses = append(statuses, s)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for _, mig := range m.Migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
+
+// withLock runs fn on a single connection holding the advisory lock,
+// the lock is session level so every statement must use conn. SQLite has
+// no advisory locks, its connection pool is limited to one connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.Dialect == database.Postgres {
+		if _, err := conn.ExecContext(ctx, `select pg_advisory_lock($1)`, lockID); err != nil {
+			return fmt.Errorf("migrations: acquire lock: %s", err)
+		}
+		defer conn.ExecContext(context.Background(), `select pg_advisory_unlock($1)`, lockID)
+	}
+
+	const createTable = `
+		create table if not exists schema_migrations (
+			version bigint primary key,
+			name varchar(255) not null,
+			applied_at timestamp not null default current_timestamp
+		)
+	`
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var version int
+	err := conn.QueryRowContext(ctx, `select coalesce(max(version), 0) from schema_migrations`).Scan(&version)
+	return version, err
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, current, target int) error {
+	if target >= current {
+		for _, mig := range m.Migrations {
+			if mig.Version <= current || mig.Version > target {
+				continue
+			}
+			const insert = `insert into schema_migrations (version, name) values ($1, $2)`
+			err := inTx(ctx, conn, mig.Up, m.Dialect.Rebind(insert), mig.Version, mig.Name)
+			if err != nil {
+				return fmt.Errorf("migrations: up %04d_%s: %s", mig.Version, mig.Name, err)
+			}
+		}
+		return nil
+	}
+
+	for i := len(m.Migrations) - 1; i >= 0; i-- {
+		mig := m.Migrations[i]
+		if mig.Version > current || mig.Version <= target {
+			continue
+		}
+		if mig.Down == "" {
+			return fmt.Errorf("migrations: %04d_%s has no down migration", mig.Version, mig.Name)
+		}
+		const remove = `delete from schema_migrations where version = $1`
+		err := inTx(ctx, conn, mig.Down, m.Dialect.Rebind(remove), mig.Version)
+		if err != nil {
+			return fmt.Errorf("migrations: down %04d_%s: %s", mig.Version, mig.Name, err)
+		}
+	}
+	return nil
+}
+
+// inTx runs a migration script and its schema_migrations bookkeeping atomically
+func inTx(ctx context.Context, conn *sql.Conn, script, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,9 @@
+-- "if not exists" adopts the databases created from the former
+-- database/database.sql, which have the table but no schema_migrations
+create table if not exists jobs (
+    id serial primary key,
+    title varchar(150) not null,
+    description text not null,
+    user_id int not null,
+    created_at timestamp default current_timestamp
+);
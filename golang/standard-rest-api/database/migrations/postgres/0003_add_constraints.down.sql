@@ -0,0 +1,3 @@
+drop index jobs_user_id_idx;
+alter table jobs drop constraint jobs_user_id_fkey;
+alter table users drop constraint users_email_key;
@@ -0,0 +1,7 @@
+-- Fails when users already share an email, merge them before migrating
+alter table users add constraint users_email_key unique (email);
+
+alter table jobs add constraint jobs_user_id_fkey
+    foreign key (user_id) references users (id) on delete cascade;
+
+create index jobs_user_id_idx on jobs (user_id);
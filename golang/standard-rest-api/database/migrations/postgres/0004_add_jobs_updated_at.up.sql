@@ -0,0 +1,2 @@
+alter table jobs add column updated_at timestamp not null default current_timestamp;
+update jobs set updated_at = created_at where created_at is not null;
@@ -0,0 +1,10 @@
+-- "if not exists" adopts the databases created from the former
+-- database/database.sql, which have the table but no schema_migrations
+create table if not exists users (
+    id integer primary key autoincrement,
+    name varchar(60) not null,
+    email varchar(150) not null,
+    password char(64) not null,
+    salt char(32) not null,
+    created_at timestamp default current_timestamp
+);
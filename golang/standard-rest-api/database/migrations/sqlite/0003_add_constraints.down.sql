@@ -0,0 +1,13 @@
+create table jobs_old (
+    id integer primary key autoincrement,
+    title varchar(150) not null,
+    description text not null,
+    user_id int not null,
+    created_at timestamp default current_timestamp
+);
+insert into jobs_old (id, title, description, user_id, created_at)
+    select id, title, description, user_id, created_at from jobs;
+drop table jobs;
+alter table jobs_old rename to jobs;
+
+drop index users_email_key;
@@ -0,0 +1,17 @@
+-- Fails when users already share an email, merge them before migrating
+create unique index users_email_key on users (email);
+
+-- SQLite can't add a foreign key to an existing table, rebuild it
+create table jobs_new (
+    id integer primary key autoincrement,
+    title varchar(150) not null,
+    description text not null,
+    user_id int not null references users (id) on delete cascade,
+    created_at timestamp default current_timestamp
+);
+insert into jobs_new (id, title, description, user_id, created_at)
+    select id, title, description, user_id, created_at from jobs;
+drop table jobs;
+alter table jobs_new rename to jobs;
+
+create index jobs_user_id_idx on jobs (user_id);
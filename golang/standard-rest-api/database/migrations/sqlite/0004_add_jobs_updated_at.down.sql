@@ -0,0 +1,14 @@
+-- SQLite can't drop a column before 3.35, rebuild the table
+create table jobs_old (
+    id integer primary key autoincrement,
+    title varchar(150) not null,
+    description text not null,
+    user_id int not null references users (id) on delete cascade,
+    created_at timestamp default current_timestamp
+);
+insert into jobs_old (id, title, description, user_id, created_at)
+    select id, title, description, user_id, created_at from jobs;
+drop table jobs;
+alter table jobs_old rename to jobs;
+
+create index jobs_user_id_idx on jobs (user_id);
@@ -0,0 +1,4 @@
+-- SQLite can't add a column with a non constant default, the repository
+-- sets updated_at on every insert and update instead
+alter table jobs add column updated_at timestamp;
+update jobs set updated_at = coalesce(created_at, current_timestamp);
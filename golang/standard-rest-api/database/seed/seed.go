@@ -0,0 +1,96 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/golang/standard-rest-api/repositories"
+)
+
+// DefaultPassword is the password of every seeded user
+const DefaultPassword = "password"
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carmen", "David", "Elena", "Farid", "Grace", "Hiro", "Ines", "Jonas",
+		"Kofi", "Lena", "Mateo", "Nadia", "Omar", "Priya", "Quinn", "Rosa", "Sven", "Tariq", "Uma", "Viktor", "Wen", "Yara"}
+	lastNames = []string{"Andersen", "Brown", "Chen", "Dubois", "Esposito", "Fischer", "Garcia", "Haddad", "Ivanova",
+		"Jensen", "Kowalski", "Lopez", "Moreau", "Nakamura", "Okafor", "Patel", "Rossi", "Schmidt", "Tanaka", "Novak"}
+
+	levels = []string{"Junior", "", "", "Senior", "Staff", "Lead", "Principal"}
+	roles  = []string{"Backend Engineer", "Frontend Developer", "Full Stack Engineer", "Site Reliability Engineer",
+		"Data Engineer", "Mobile Developer", "QA Engineer", "DevOps Engineer", "Security Engineer",
+		"Product Designer", "Engineering Manager", "Machine Learning Engineer"}
+	stacks = []string{"Go", "PostgreSQL", "Redis", "Kubernetes", "React", "TypeScript", "Kafka", "Terraform",
+		"gRPC", "AWS", "GCP", "Python", "Swift", "Kotlin", "Elasticsearch"}
+	companies = []string{"Acme Corp", "Globex", "Initech", "Umbrella Labs", "Hooli", "Stark Industries",
+		"Wayne Enterprises", "Vandelay Industries", "Soylent", "Cyberdyne"}
+	cities = []string{"Berlin", "Lisbon", "Toronto", "Singapore", "Austin", "Remote", "Amsterdam", "Nairobi", "Tokyo"}
+	perks  = []string{"a learning budget", "flexible hours", "a four day week in summer", "stock options",
+		"a home office budget", "30 days of vacation", "yearly team offsites", "parental leave top-ups"}
+)
+
+// Options of a seed run, the same Seed always produces the same data
+type Options struct {
+	Users int
+	Jobs  int
+	Seed  int64
+}
+
+// Result holds the ids of the created records
+type Result struct {
+	UserIDs []int
+	JobIDs  []int
+}
+
+// Run creates opts.Users users and spreads opts.Jobs jobs over them
+func Run(ctx context.Context, users repositories.UserRepository, jobs repositories.JobRepository, opts *Options) (*Result, error) {
+	if opts.Jobs > 0 && opts.Users < 1 {
+		return nil, fmt.Errorf("seed: jobs need at least one user")
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	res := &Result{}
+
+	for i := 0; i < opts.Users; i++ {
+		first := pick(rnd, firstNames)
+		last := pick(rnd, lastNames)
+		// The index keeps emails unique whatever names are drawn
+		email := fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), i+1)
+		id, err := users.CreateUser(ctx, email, first+" "+last, DefaultPassword)
+		if err != nil {
+			return res, fmt.Errorf("seed: create user %s: %w", email, err)
+		}
+		res.UserIDs = append(res.UserIDs, id)
+	}
+
+	for i := 0; i < opts.Jobs; i++ {
+		title, description := job(rnd)
+		userID := res.UserIDs[rnd.Intn(len(res.UserIDs))]
+		id, err := jobs.CreateJob(ctx, title, description, userID)
+		if err != nil {
+			return res, fmt.Errorf("seed: create job %q: %w", title, err)
+		}
+		res.JobIDs = append(res.JobIDs, id)
+	}
+	return res, nil
+}
+
+func pick(rnd *rand.Rand, values []string) string {
+	return values[rnd.Intn(len(values))]
+}
+
+func job(rnd *rand.Rand) (string, string) {
+	title := strings.TrimSpace(pick(rnd, levels) + " " + pick(rnd, roles))
+	company := pick(rnd, companies)
+	city := pick(rnd, cities)
+
+	// Draw distinct technologies and perks
+	stack := rnd.Perm(len(stacks))[:3]
+	perk := rnd.Perm(len(perks))[:2]
+	description := fmt.Sprintf("%s is hiring a new %s in %s. You will work with %s, %s and %s on a team of %d, "+
+		"and help us ship features to over %d customers. We offer %s and %s.",
+		company, title, city, stacks[stack[0]], stacks[stack[1]], stacks[stack[2]], 3+rnd.Intn(10),
+		(1+rnd.Intn(50))*1000, perks[perk[0]], perks[perk[1]])
+	return title, description
+}
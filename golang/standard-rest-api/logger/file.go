@@ -12,6 +12,14 @@ import (
 	"io"
 	"path/filepath"
 	"github.com/golang/standard-rest-api/utils/archive"
+	"github.com/golang/standard-rest-api/utils/metrics"
+)
+
+var (
+	logWrites = metrics.NewCounter("log_writes_total", "Number of log messages written.", "level")
+	logWriteErrors = metrics.NewCounter("log_write_errors_total", "Number of log messages that failed to be written.")
+	logRotations = metrics.NewCounter("log_rotations_total", "Number of log file rotations.")
+	logRotationErrors = metrics.NewCounter("log_rotation_errors_total", "Number of failed log file rotations.")
 )
 
 // fileLogWriter implements LoggerInterface.
@@ -129,14 +137,19 @@ func (f *FileLogWriter) WriteMsg(level int, msg string, v ...interface{}) error
 	defer f.Unlock()
 
 	if f.needRotate() {
+		logRotations.Inc()
 		if err := f.doRotate(); err != nil {
+			logRotationErrors.Inc()
 			fmt.Fprintf(os.Stderr, "doRotate failed, error:%s\n", err)
 		}
 	}
 
 	_, err := f.fileWriter.Write([]byte(msg))
 	if err != nil {
+		logWriteErrors.Inc()
 		fmt.Fprintf(os.Stderr, "write log messsage failed, error:%s\n", err)
+	} else {
+		logWrites.Inc(levelPrefix[level])
 	}
 
 	return err
@@ -159,7 +172,7 @@ func (f *FileLogWriter) doRotate() error {
 
 		err := os.Rename(oldLogName, newLogName)
 		if err != nil {
-			fmt.Errorf("rename %s", err)
+			fmt.Fprintf(os.Stderr, "rename %s error:%s\n", oldLogName, err)
 		}
 	}
 
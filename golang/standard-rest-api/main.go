@@ -2,30 +2,287 @@ package main
 
 import (
 	"os"
+	"os/signal"
+	"syscall"
+	"context"
+	"database/sql"
 	"log"
 	"net/http"
+	"time"
+	"github.com/golang/standard-rest-api/config"
+	"github.com/golang/standard-rest-api/utils/env"
 	"github.com/golang/standard-rest-api/utils/database"
 	"github.com/golang/standard-rest-api/utils/caching"
 	"github.com/golang/standard-rest-api/controllers"
+	"github.com/golang/standard-rest-api/logger"
+	"github.com/golang/standard-rest-api/middlewares"
+	"github.com/golang/standard-rest-api/repositories"
 	"github.com/golang/standard-rest-api/routers"
+	"github.com/golang/standard-rest-api/utils/metrics"
+	"github.com/golang/standard-rest-api/utils/tracing"
 )
 
 func main() {
-	db, err := database.Connect(os.Getenv("PGUSER"), os.Getenv("PGPASS"), os.Getenv("PGDB"), os.Getenv("PGHOST"), os.Getenv("PGPORT"))
+	confFile := os.Getenv("CONF_FILE")
+	if confFile == "" {
+		confFile = env.GetConfPath() + env.PathSeparator + "app.conf"
+	}
+	conf, err := config.NewConfig("ini", confFile)
+	if err != nil {
+		log.Fatalf("Load config %s error:%s", confFile, err)
+	}
+
+	logPath := conf.DefaultString("log::path", env.GetLogPath())
+	if err := os.MkdirAll(logPath, 0755); err != nil {
+		log.Fatalf("Create log directory %s error:%s", logPath, err)
+	}
+	err = logger.Init(conf.DefaultString("log::file", logger.LogName), logPath,
+		conf.DefaultInt("log::level", logger.LevelInfo),
+		conf.DefaultInt("log::count", logger.LogCount),
+		conf.DefaultInt("log::max_size", logger.LogMaxSize))
 	if err != nil {
 		log.Fatal(err)
 	}
-	cache := &caching.Redis{
-		Client: caching.Connect(os.Getenv("REDIS_ADDR"), os.Getenv("REDIS_PASSWORD"), 0),
+
+	var (
+		db *sql.DB
+		cluster *database.Cluster
+		dialect database.Dialect
+		users repositories.UserRepository
+		jobs repositories.JobRepository
+		// the SQL repositories, for the commands running in a transaction
+		sqlUsers *repositories.SQLUserRepository
+		sqlJobs *repositories.SQLJobRepository
+	)
+	switch exporter := conf.DefaultString("log::trace_exporter", "none"); exporter {
+	case "none":
+	case "log":
+		tracing.SetExporter(tracing.LogExporter())
+	default:
+		log.Fatalf("Unknown trace exporter %q", exporter)
+	}
+
+	queryTimeout := conf.DefaultDuration("database::query_timeout", repositories.DefaultQueryTimeout)
+	observers := []database.QueryObserver{
+		database.MetricsObserver,
+		database.TracingObserver,
+		database.SlowQueryLogger(conf.DefaultDuration("database::slow_query_threshold", 200*time.Millisecond)),
+	}
+	switch driver := conf.DefaultString("database::driver", "postgres"); driver {
+	case "postgres":
+		dsn := database.NewDSN(conf)
+		log.Printf("Connecting to %s", dsn.Redacted())
+		db, err = database.Connect(context.Background(), dsn, database.NewOptions(conf))
+		if err != nil {
+			log.Fatal(err)
+		}
+		dialect = database.Postgres
+		cluster = newCluster(conf, db, dsn, observers)
+
+		pgUsers := repositories.NewPostgresUserRepository(database.Instrument(db, observers...), queryTimeout)
+		pgUsers.Replicas = cluster
+		pgJobs := repositories.NewPostgresJobRepository(database.Instrument(db, observers...), queryTimeout)
+		pgJobs.Replicas = cluster
+		sqlUsers, sqlJobs = pgUsers, pgJobs
+		users, jobs = pgUsers, pgJobs
+	case "sqlite":
+		path := conf.DefaultString("database::sqlite_path", "standard-rest.db")
+		db, err = database.ConnectSQLite(path)
+		if err != nil {
+			log.Fatalf("Open sqlite database %s error:%s", path, err)
+		}
+		dialect = database.SQLite
+		sqlUsers = repositories.NewSQLiteUserRepository(database.Instrument(db, observers...), queryTimeout)
+		sqlJobs = repositories.NewSQLiteJobRepository(database.Instrument(db, observers...), queryTimeout)
+		users, jobs = sqlUsers, sqlJobs
+	case "memory":
+		log.Print("Using the in-memory repositories, data is lost on restart")
+		memoryUsers := repositories.NewMemoryUserRepository()
+		users, jobs = memoryUsers, repositories.NewMemoryJobRepository(memoryUsers)
+	default:
+		log.Fatalf("Unknown database driver %q", driver)
+	}
+	if db != nil {
+		metrics.RegisterDB("primary", db)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if db == nil {
+				log.Fatal("migrate requires the postgres or sqlite database driver")
+			}
+			if err := runMigrate(db, dialect, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			db.Close()
+			return
+		case "seed":
+			if db == nil {
+				log.Fatal("seed requires the postgres or sqlite database driver")
+			}
+			if err := runSeed(db, sqlUsers, sqlJobs, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			db.Close()
+			return
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+	}
+
+	backend, closeCache := newCache(conf)
+	cache := caching.NewNamespaced(backend,
+		conf.DefaultString("cache::namespace", "rest")+":v"+conf.DefaultString("cache::version", "1")+":",
+		conf.DefaultDuration("cache::slow_threshold", 50*time.Millisecond))
+
+	if db != nil && conf.DefaultBool("database::auto_migrate", false) {
+		wait := conf.DefaultDuration("database::migrate_lock_timeout", 5*time.Minute)
+		if err := autoMigrate(db, dialect, caching.NewLocker(cache), wait); err != nil {
+			log.Fatal(err)
+		}
 	}
+	loading := caching.NewLoadingCache(cache,
+		conf.DefaultDuration("cache::stale_ttl", time.Minute),
+		repositories.ErrNotFound,
+		conf.DefaultDuration("cache::negative_ttl", 10*time.Second))
+	loading.LoadTimeout = conf.DefaultDuration("cache::load_timeout", loading.LoadTimeout)
+	jobs = repositories.NewCachedJobRepository(jobs, loading,
+		conf.DefaultDuration("cache::job_ttl", repositories.DefaultJobCacheTTL),
+		conf.DefaultDuration("cache::feed_ttl", repositories.DefaultFeedCacheTTL))
 
-	userController := controllers.NewUserController(db, cache)
-	jobController := controllers.NewJobController(db, cache)
+	userController := controllers.NewUserController(users, cache)
+	jobController := controllers.NewJobController(jobs, cache)
+	jobController.JobCacheControl = conf.DefaultString("http::job_cache_control", "public, max-age=60")
+	jobController.FeedCacheControl = conf.DefaultString("http::feed_cache_control", "public, max-age=30")
+	if conf.DefaultBool("cache::legacy_tokens", true) {
+		jobController.LegacyTokens = backend
+	}
+	healthController := controllers.NewHealthController(db, cache, conf.DefaultDuration("health::timeout", 2*time.Second))
 
 	mux := http.NewServeMux()
-	routers.CreateRouters(mux, userController, jobController)
+	routers.CreateRouters(mux, userController, jobController, healthController)
+
+	var handler http.Handler = mux
+	if cluster != nil {
+		handler = middlewares.ReadYourWrites(conf.DefaultDuration("database::read_your_writes_window", 5*time.Second),
+			"/login", "/register")(handler)
+	}
+	corsConfig := middlewares.NewCORSConfig(conf)
+	if err := corsConfig.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	handler = middlewares.CORS(corsConfig)(handler)
+
+	server := newServer(conf, handler)
+	shutdownTimeout := conf.DefaultDuration("http::shutdown_timeout", 30*time.Second)
+	shutdownDelay := conf.DefaultDuration("http::shutdown_delay", 0)
+
+	idleClosed := make(chan struct{})
+	go func() {
+		defer close(idleClosed)
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-stop
+		log.Printf("Received %s, shutting down", sig)
+
+		// Fail readiness first and give the orchestrator time to notice
+		healthController.SetReady(false)
+		time.Sleep(shutdownDelay)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		// Stop accepting new connections and wait for in-flight requests
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("HTTP server shutdown error:%s", err)
+		}
+	}()
 
-	if err := http.ListenAndServe(":8080", mux); err != nil {
+	log.Printf("Listening on %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
+	<-idleClosed
+
+	// Requests are drained, release the backends in order
+	if cluster != nil {
+		if err := cluster.Close(); err != nil {
+			log.Printf("Close database error:%s", err)
+		}
+	} else if db != nil {
+		if err := db.Close(); err != nil {
+			log.Printf("Close database error:%s", err)
+		}
+	}
+	if err := closeCache(); err != nil {
+		log.Printf("Close cache error:%s", err)
+	}
+	log.Print("Server stopped")
+}
+
+// newCluster opens the read replicas of the [database] section, they are
+// dialed lazily so an unreachable replica doesn't prevent the startup.
+func newCluster(conf config.Configer, primary *sql.DB, dsn *database.DSN, observers []database.QueryObserver) *database.Cluster {
+	cluster := database.NewCluster(primary, observers...)
+	opts := database.NewOptions(conf)
+	for _, addr := range conf.DefaultStrings("database::replicas", nil) {
+		replica, err := database.Open(dsn.Replica(addr), opts)
+		if err != nil {
+			log.Fatalf("Open replica %s error:%s", addr, err)
+		}
+		cluster.AddReplica(addr, replica)
+		metrics.RegisterDB(addr, replica)
+	}
+	cluster.StartHealthChecks(
+		conf.DefaultDuration("database::replica_check_interval", 5*time.Second),
+		conf.DefaultDuration("database::replica_check_timeout", time.Second))
+	return cluster
+}
+
+// newCache returns the cache of the [cache] section and the func releasing it
+func newCache(conf config.Configer) (caching.Cache, func() error) {
+	switch driver := conf.DefaultString("cache::driver", "redis"); driver {
+	case "redis":
+		r := newRedis(conf)
+		return r, r.Close
+	case "memory":
+		m := caching.NewMemory(conf.DefaultInt("cache::max_entries", 10000),
+			conf.DefaultDuration("cache::cleanup_interval", time.Minute))
+		return m, m.Close
+	case "layered":
+		r := newRedis(conf)
+		l1 := caching.NewMemory(conf.DefaultInt("cache::max_entries", 10000),
+			conf.DefaultDuration("cache::cleanup_interval", time.Minute))
+		invalidator := caching.NewRedisInvalidator(r.Client,
+			conf.DefaultString("cache::invalidation_channel", "cache:invalidate"))
+		c := caching.NewLayered(l1, r, conf.DefaultDuration("cache::l1_ttl", 10*time.Second), invalidator)
+		return c, func() error {
+			c.Close()
+			return r.Close()
+		}
+	default:
+		log.Fatalf("Unknown cache driver %q", driver)
+		return nil, nil
+	}
+}
+
+func newRedis(conf config.Configer) *caching.Redis {
+	addr := conf.DefaultString("cache::redis_addr", os.Getenv("REDIS_ADDR"))
+	password := conf.DefaultString("cache::redis_password", os.Getenv("REDIS_PASSWORD"))
+	return &caching.Redis{
+		Client: caching.Connect(addr, password, conf.DefaultInt("cache::redis_db", 0)),
+	}
+}
+
+// newServer builds the http.Server from the [http] config section
+func newServer(conf config.Configer, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              conf.DefaultString("http::addr", ":8080"),
+		Handler:           handler,
+		ReadTimeout:       conf.DefaultDuration("http::read_timeout", 10*time.Second),
+		ReadHeaderTimeout: conf.DefaultDuration("http::read_header_timeout", 5*time.Second),
+		WriteTimeout:      conf.DefaultDuration("http::write_timeout", 15*time.Second),
+		IdleTimeout:       conf.DefaultDuration("http::idle_timeout", 60*time.Second),
+		MaxHeaderBytes:    conf.DefaultInt("http::max_header_bytes", http.DefaultMaxHeaderBytes),
+	}
 }
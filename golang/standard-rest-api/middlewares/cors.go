@@ -0,0 +1,143 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/standard-rest-api/config"
+)
+
+var (
+	defaultAllowMethods = []string{"GET", "POST", "PUT", "DELETE"}
+	defaultAllowHeaders = []string{"Content-Type", "Accept", "token"}
+)
+
+// CORSConfig holds the cross-origin settings of the [cors] config section
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+// NewCORSConfig reads the [cors] section, e.g.
+//	[cors]
+//	allow_origins = https://app.example.com;https://admin.example.com
+//	allow_methods = GET;POST;PUT;DELETE
+//	allow_headers = Content-Type;token
+//	allow_credentials = false
+//	max_age = 600
+func NewCORSConfig(conf config.Configer) *CORSConfig {
+	return &CORSConfig{
+		AllowOrigins:     conf.DefaultStrings("cors::allow_origins", nil),
+		AllowMethods:     conf.DefaultStrings("cors::allow_methods", defaultAllowMethods),
+		AllowHeaders:     conf.DefaultStrings("cors::allow_headers", defaultAllowHeaders),
+		ExposeHeaders:    conf.DefaultStrings("cors::expose_headers", nil),
+		AllowCredentials: conf.DefaultBool("cors::allow_credentials", false),
+		MaxAge:           conf.DefaultInt("cors::max_age", 600),
+	}
+}
+
+// Validate rejects a wildcard origin with credentials, browsers refuse the
+// combination and echoing every origin instead would let any site make
+// authenticated requests.
+func (c *CORSConfig) Validate() error {
+	for _, o := range c.AllowOrigins {
+		if o == "*" && c.AllowCredentials {
+			return errors.New("cors: allow_origins = * can't be combined with allow_credentials = true")
+		}
+	}
+	return nil
+}
+
+// anyOrigin reports whether every origin gets the same headers, in which
+// case responses don't vary by Origin.
+func (c *CORSConfig) anyOrigin() bool {
+	return len(c.AllowOrigins) == 1 && c.AllowOrigins[0] == "*" && !c.AllowCredentials
+}
+
+func (c *CORSConfig) allowOrigin(origin string) (string, bool) {
+	for _, o := range c.AllowOrigins {
+		if o == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(o, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+func (c *CORSConfig) allowMethod(method string) bool {
+	for _, m := range c.AllowMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS answers preflight requests before they reach the controllers and
+// adds the Access-Control-* headers to the actual cross-origin requests.
+func CORS(c *CORSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Shared caches must not serve a response with or without the
+			// Access-Control-* headers to another origin
+			if !c.anyOrigin() {
+				w.Header().Add("Vary", "Origin")
+			}
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			allowed, ok := c.allowOrigin(origin)
+			if !ok {
+				if preflight {
+					http.Error(w, "Origin not allowed", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", allowed)
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(c.ExposeHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if !c.allowMethod(r.Header.Get("Access-Control-Request-Method")) {
+				http.Error(w, "Method not allowed", http.StatusForbidden)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowMethods, ", "))
+			if len(c.AllowHeaders) == 1 && c.AllowHeaders[0] == "*" {
+				h.Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+			} else {
+				h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowHeaders, ", "))
+			}
+			if c.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
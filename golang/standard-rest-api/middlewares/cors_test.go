@@ -0,0 +1,162 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveCORS(c *CORSConfig, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	})
+	w := httptest.NewRecorder()
+	CORS(c)(next).ServeHTTP(w, r)
+	return w, reached
+}
+
+func preflight(origin, method string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, "/jobs", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	r.Header.Set("Access-Control-Request-Headers", "content-type, token")
+	return r
+}
+
+func varies(h http.Header, name string) bool {
+	for _, v := range h.Values("Vary") {
+		if strings.EqualFold(v, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func testCORSConfig() *CORSConfig {
+	return &CORSConfig{
+		AllowOrigins:  []string{"https://app.example.com"},
+		AllowMethods:  defaultAllowMethods,
+		AllowHeaders:  defaultAllowHeaders,
+		ExposeHeaders: []string{"ETag"},
+		MaxAge:        600,
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	w, reached := serveCORS(testCORSConfig(), preflight("https://app.example.com", "PUT"))
+	if reached {
+		t.Error("the preflight reached the handler")
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status == %d, want 204", w.Code)
+	}
+	h := w.Header()
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE",
+		"Access-Control-Allow-Headers": "Content-Type, Accept, token",
+		"Access-Control-Max-Age":       "600",
+	}
+	for name, v := range want {
+		if got := h.Get(name); got != v {
+			t.Errorf("%s == %q, want %q", name, got, v)
+		}
+	}
+	if h.Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("Access-Control-Allow-Credentials set without allow_credentials")
+	}
+	for _, name := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+		if !varies(h, name) {
+			t.Errorf("Vary doesn't list %s", name)
+		}
+	}
+}
+
+func TestCORSPreflightRejected(t *testing.T) {
+	cases := []struct {
+		origin, method string
+	}{
+		{"https://evil.example.com", "GET"},
+		{"https://app.example.com", "PATCH"},
+	}
+	for _, c := range cases {
+		w, reached := serveCORS(testCORSConfig(), preflight(c.origin, c.method))
+		if reached || w.Code != http.StatusForbidden {
+			t.Errorf("preflight of %s %s == %d, reached %v, want 403", c.origin, c.method, w.Code, reached)
+		}
+		if w.Header().Get("Access-Control-Allow-Methods") != "" {
+			t.Errorf("preflight of %s %s lists the allowed methods", c.origin, c.method)
+		}
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w, reached := serveCORS(testCORSConfig(), r)
+	if !reached {
+		t.Fatal("the request didn't reach the handler")
+	}
+	h := w.Header()
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin == %q", got)
+	}
+	if got := h.Get("Access-Control-Expose-Headers"); got != "ETag" {
+		t.Errorf("Access-Control-Expose-Headers == %q, want \"ETag\"", got)
+	}
+
+	// A disallowed origin still reaches the handler, without the headers
+	r.Header.Set("Origin", "https://evil.example.com")
+	w, reached = serveCORS(testCORSConfig(), r)
+	if !reached || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin: reached %v, Access-Control-Allow-Origin %q", reached, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if !varies(w.Header(), "Origin") {
+		t.Error("the response to a disallowed origin doesn't vary on Origin")
+	}
+}
+
+func TestCORSVary(t *testing.T) {
+	cases := []struct {
+		origins     []string
+		credentials bool
+		want        bool
+	}{
+		{[]string{"https://app.example.com"}, false, true},
+		{[]string{"*"}, false, false},
+		{[]string{"*", "https://app.example.com"}, false, true},
+	}
+	for _, c := range cases {
+		conf := testCORSConfig()
+		conf.AllowOrigins, conf.AllowCredentials = c.origins, c.credentials
+		// Same-origin requests are cached too, they vary as well
+		for _, origin := range []string{"", "https://app.example.com"} {
+			r := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+			if origin != "" {
+				r.Header.Set("Origin", origin)
+			}
+			w, _ := serveCORS(conf, r)
+			if got := varies(w.Header(), "Origin"); got != c.want {
+				t.Errorf("allow_origins %q, Origin %q: Vary: Origin == %v, want %v", c.origins, origin, got, c.want)
+			}
+		}
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	conf := testCORSConfig()
+	conf.AllowOrigins, conf.AllowCredentials = []string{"*"}, true
+	if conf.Validate() == nil {
+		t.Error("Validate accepted allow_origins = * with allow_credentials")
+	}
+	conf.AllowCredentials = false
+	if err := conf.Validate(); err != nil {
+		t.Errorf("Validate error: %s", err)
+	}
+	conf.AllowOrigins, conf.AllowCredentials = []string{"https://app.example.com"}, true
+	if err := conf.Validate(); err != nil {
+		t.Errorf("Validate error: %s", err)
+	}
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("http_requests_total",
+		"Number of HTTP requests.", "route", "method", "status")
+	httpDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"HTTP request latencies in seconds.", nil, "route", "method", "status")
+)
+
+// statusRecorder remembers the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Metrics records the request count and latency of next under route,
+// the mux pattern is used rather than the path to keep cardinality low.
+func Metrics(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(sr, r)
+
+		method, status := methodLabel(r.Method), strconv.Itoa(sr.status)
+		httpRequests.Inc(route, method, status)
+		httpDuration.Observe(time.Since(start).Seconds(), route, method, status)
+	}
+}
+
+// methodLabel maps the method to one of the standard ones, clients can
+// send any token as a method and must not be able to add label values.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/database"
+)
+
+// primaryCookie marks a client that mutated something recently
+const primaryCookie = "read_primary"
+
+// mutationRecorder sets the cookie once a mutation is known to succeed
+type mutationRecorder struct {
+	http.ResponseWriter
+	window      time.Duration
+	wroteHeader bool
+}
+
+func (mr *mutationRecorder) WriteHeader(status int) {
+	if !mr.wroteHeader && status < http.StatusBadRequest {
+		mr.setCookie()
+	}
+	mr.wroteHeader = true
+	mr.ResponseWriter.WriteHeader(status)
+}
+
+func (mr *mutationRecorder) setCookie() {
+	http.SetCookie(mr.ResponseWriter, &http.Cookie{
+		Name:     primaryCookie,
+		Value:    "1",
+		Path:     "/",
+		MaxAge:   maxAge(mr.window),
+		HttpOnly: true,
+	})
+}
+
+// maxAge rounds window up to whole seconds, a MaxAge of 0 would leave
+// Max-Age out and make a session cookie
+func maxAge(window time.Duration) int {
+	if seconds := int(math.Ceil(window.Seconds())); seconds > 1 {
+		return seconds
+	}
+	return 1
+}
+
+func (mr *mutationRecorder) Write(b []byte) (int, error) {
+	if !mr.wroteHeader {
+		mr.WriteHeader(http.StatusOK)
+	}
+	return mr.ResponseWriter.Write(b)
+}
+
+// ReadYourWrites sends the reads of a client to the primary for window
+// after it successfully created, updated or deleted something, so it
+// doesn't see stale data from a lagging replica. The requests to the
+// except paths, e.g. /login, write nothing read from a replica and don't
+// set the cookie.
+func ReadYourWrites(window time.Duration, except ...string) func(http.Handler) http.Handler {
+	skip := make(map[string]bool, len(except))
+	for _, path := range except {
+		skip[path] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := r.Cookie(primaryCookie); err == nil {
+				r = r.WithContext(database.WithPrimary(r.Context()))
+			}
+			if skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			switch r.Method {
+			case "POST", "PUT", "PATCH", "DELETE":
+				mr := &mutationRecorder{ResponseWriter: w, window: window}
+				next.ServeHTTP(mr, r)
+				// A handler that wrote nothing answers an implicit 200
+				if !mr.wroteHeader {
+					mr.setCookie()
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
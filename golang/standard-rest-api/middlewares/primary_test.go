@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/database"
+)
+
+// serveRYW runs h behind ReadYourWrites and returns the read_primary
+// cookie it set, if any
+func serveRYW(window time.Duration, r *http.Request, h http.HandlerFunc) *http.Cookie {
+	w := httptest.NewRecorder()
+	ReadYourWrites(window, "/login", "/register")(h).ServeHTTP(w, r)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == primaryCookie {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestReadYourWritesCookie(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	created := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) }
+	invalid := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	}
+	cases := []struct {
+		method, path string
+		window       time.Duration
+		handler      http.HandlerFunc
+		maxAge       int // 0 when no cookie is expected
+	}{
+		{"POST", "/job", 5 * time.Second, created, 5},
+		{"PUT", "/job/1", 5 * time.Second, ok, 5},
+		{"DELETE", "/job/1", 1500 * time.Millisecond, ok, 2},
+		// Under a second is rounded up, not turned into a session cookie
+		{"DELETE", "/job/1", 200 * time.Millisecond, ok, 1},
+		{"POST", "/job", 5 * time.Second, invalid, 0},
+		{"GET", "/job/1", 5 * time.Second, ok, 0},
+		{"POST", "/login", 5 * time.Second, ok, 0},
+		{"POST", "/register", 5 * time.Second, ok, 0},
+	}
+	for _, c := range cases {
+		cookie := serveRYW(c.window, httptest.NewRequest(c.method, c.path, nil), c.handler)
+		switch {
+		case c.maxAge == 0 && cookie != nil:
+			t.Errorf("%s %s set the cookie", c.method, c.path)
+		case c.maxAge != 0 && cookie == nil:
+			t.Errorf("%s %s didn't set the cookie", c.method, c.path)
+		case cookie != nil && cookie.MaxAge != c.maxAge:
+			t.Errorf("%s %s with a %s window: Max-Age == %d, want %d", c.method, c.path, c.window, cookie.MaxAge, c.maxAge)
+		}
+	}
+}
+
+func TestReadYourWritesRoutesToPrimary(t *testing.T) {
+	for _, withCookie := range []bool{false, true} {
+		r := httptest.NewRequest("GET", "/job/1", nil)
+		if withCookie {
+			r.AddCookie(&http.Cookie{Name: primaryCookie, Value: "1"})
+		}
+		var primary bool
+		serveRYW(time.Second, r, func(w http.ResponseWriter, r *http.Request) {
+			primary = database.UsePrimary(r.Context())
+		})
+		if primary != withCookie {
+			t.Errorf("cookie %v: UsePrimary == %v", withCookie, primary)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/golang/standard-rest-api/utils/tracing"
+)
+
+// Tracing runs next in a span named after route, continuing the trace of
+// the caller's traceparent header. The spans of the queries of the
+// request are its children.
+func Tracing(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.WithRemoteParent(r.Context(), r.Header.Get("traceparent"))
+		ctx, span := tracing.Start(ctx, "http "+route)
+		if span == nil {
+			next(w, r)
+			return
+		}
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(sr, r.WithContext(ctx))
+
+		span.SetAttribute("http.method", methodLabel(r.Method))
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.status_code", strconv.Itoa(sr.status))
+		span.End(nil)
+	}
+}
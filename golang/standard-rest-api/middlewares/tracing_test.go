@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/standard-rest-api/utils/tracing"
+)
+
+func TestTracing(t *testing.T) {
+	var spans []*tracing.Span
+	tracing.SetExporter(tracing.ExporterFunc(func(s *tracing.Span) {
+		spans = append(spans, s)
+	}))
+	defer tracing.SetExporter(nil)
+
+	var inner *tracing.Span
+	h := Tracing("/job/", func(w http.ResponseWriter, r *http.Request) {
+		inner = tracing.FromContext(r.Context())
+		http.Error(w, "Not Found", http.StatusNotFound)
+	})
+	req := httptest.NewRequest("GET", "/job/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h(httptest.NewRecorder(), req)
+
+	if len(spans) != 1 || spans[0] != inner {
+		t.Fatalf("exported %d spans, want the request span the handler saw", len(spans))
+	}
+	s := spans[0]
+	if s.Name != "http /job/" || s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || s.ParentID != "00f067aa0ba902b7" {
+		t.Errorf("request span == %+v, want a child of the traceparent", s)
+	}
+	if s.Attributes["http.status_code"] != "404" || s.Attributes["http.method"] != "GET" {
+		t.Errorf("request span attributes == %v", s.Attributes)
+	}
+}
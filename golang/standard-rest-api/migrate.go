@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/standard-rest-api/database/migrations"
+	"github.com/golang/standard-rest-api/utils/caching"
+	"github.com/golang/standard-rest-api/utils/database"
+)
+
+const migrateUsage = "usage: migrate up|down|status|to <version>"
+
+// runMigrate implements the migrate command
+func runMigrate(db *sql.DB, dialect database.Dialect, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+	m, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx)
+	case "to":
+		if len(args) != 2 {
+			return fmt.Errorf(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return m.To(ctx, version)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf(migrateUsage)
+}
+
+// migrateLockTTL is the lease of the auto_migrate lock, it is extended
+// every third of it while the migrations run.
+const migrateLockTTL = 30 * time.Second
+
+// autoMigrate runs the pending migrations at startup while holding the
+// "migrate" lock, so the instances of a rollout take turns instead of all
+// opening a migrator at once. The ones that wait find nothing to do.
+func autoMigrate(db *sql.DB, dialect database.Dialect, locker *caching.Locker, wait time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	lock, err := locker.Acquire(ctx, "migrate", migrateLockTTL)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("acquire the migrate lock: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(migrateLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := lock.Extend(migrateLockTTL); err != nil {
+					log.Printf("Extend the migrate lock error:%s", err)
+				}
+			}
+		}
+	}()
+
+	err = runMigrate(db, dialect, []string{"up"})
+	close(done)
+	if rerr := lock.Release(); rerr != nil {
+		log.Printf("Release the migrate lock error:%s", rerr)
+	}
+	return err
+}
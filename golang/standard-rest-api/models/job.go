@@ -1,8 +1,11 @@
 package models
 
+import "time"
+
 type Job struct {
-	ID int `json:"id"`
-	Title string `json:"title"`
-	Description string `json:"description"`
-	UserID string `json:"user_id"`
+	ID int `json:"id" xml:"id"`
+	Title string `json:"title" xml:"title"`
+	Description string `json:"description" xml:"description"`
+	UserID string `json:"user_id" xml:"user_id"`
+	UpdatedAt time.Time `json:"updated_at" xml:"updated_at"`
 }
@@ -1,9 +1,9 @@
 package models
 
 type User struct{
-	ID int `json:"id"`
-	Email string `json:"email"`
-	Name string `json:"name"`
+	ID int `json:"id" xml:"id"`
+	Email string `json:"email" xml:"email"`
+	Name string `json:"name" xml:"name"`
 }
 
 type PrivateUserDetails struct {
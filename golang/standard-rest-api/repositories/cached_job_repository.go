@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/standard-rest-api/logger"
+	"github.com/golang/standard-rest-api/models"
+	"github.com/golang/standard-rest-api/utils/caching"
+	"github.com/golang/standard-rest-api/utils/database"
+)
+
+const (
+	DefaultJobCacheTTL  = 5 * time.Minute
+	DefaultFeedCacheTTL = 30 * time.Second
+
+	// feedTag is on every feed page, creating or deleting a job shifts all
+	// of them.
+	feedTag = "feed"
+
+	// jobCacheVersion tags the cached jobs, bump it when models.Job changes
+	// so the entries of the previous release are reloaded.
+	jobCacheVersion = 1
+)
+
+// CachedJobRepository is a read-through cache in front of a JobRepository.
+// Jobs and feed pages are stored with Serializer and tagged with the jobs
+// they hold, so an update only invalidates the entries showing the job.
+// Missing jobs are cached as well when Cache has a NegativeTTL. The cache is
+// best effort: when it fails the lookups go to Jobs.
+type CachedJobRepository struct {
+	Jobs       JobRepository
+	Cache      *caching.LoadingCache
+	JobTTL     time.Duration
+	FeedTTL    time.Duration
+	Serializer *caching.Serializer
+}
+
+func NewCachedJobRepository(jobs JobRepository, cache *caching.LoadingCache, jobTTL, feedTTL time.Duration) *CachedJobRepository {
+	return &CachedJobRepository{
+		Jobs:    jobs,
+		Cache:   cache,
+		JobTTL:  jobTTL,
+		FeedTTL: feedTTL,
+		Serializer: &caching.Serializer{
+			Codec:         caching.MessagePack,
+			Version:       jobCacheVersion,
+			CompressAbove: 1024,
+		},
+	}
+}
+
+func jobKey(id int) string {
+	return fmt.Sprintf("job:%d", id)
+}
+
+func feedKey(page, resultsPerPage int) string {
+	return fmt.Sprintf("feed:%d:%d", page, resultsPerPage)
+}
+
+// jobTag is on the entry of the job and on the feed pages listing it
+func jobTag(id int) string {
+	return fmt.Sprintf("job:%d", id)
+}
+
+func (cr *CachedJobRepository) CreateJob(ctx context.Context, title, description string, userID int) (int, error) {
+	id, err := cr.Jobs.CreateJob(ctx, title, description, userID)
+	if err != nil {
+		return id, err
+	}
+	// Also drops a negative entry left by a lookup of the id before it existed
+	cr.invalidate(jobTag(id), feedTag)
+	return id, nil
+}
+
+func (cr *CachedJobRepository) UpdateJob(ctx context.Context, jobID int, title, description string) error {
+	if err := cr.Jobs.UpdateJob(ctx, jobID, title, description); err != nil {
+		return err
+	}
+	cr.invalidate(jobTag(jobID))
+	return nil
+}
+
+func (cr *CachedJobRepository) DeleteJob(ctx context.Context, id int) error {
+	if err := cr.Jobs.DeleteJob(ctx, id); err != nil {
+		return err
+	}
+	cr.invalidate(jobTag(id), feedTag)
+	return nil
+}
+
+// GetJobByID and GetJobs load the misses through the read router of Jobs.
+// A ctx marked with database.WithPrimary, e.g. by the read-your-writes
+// cookie or before a mutation, skips the cache and reads the primary.
+func (cr *CachedJobRepository) GetJobByID(ctx context.Context, id int) (*models.Job, error) {
+	if database.UsePrimary(ctx) {
+		return cr.Jobs.GetJobByID(ctx, id)
+	}
+	tags := []string{jobTag(id)}
+	val, err := cr.Cache.GetOrLoad(ctx, jobKey(id), cr.JobTTL, func(ctx context.Context) (string, []string, error) {
+		job, err := cr.Jobs.GetJobByID(ctx, id)
+		if err != nil {
+			// A negative entry is tagged as well so CreateJob drops it
+			return "", tags, err
+		}
+		val, err := cr.Serializer.Encode(job)
+		return val, tags, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var job models.Job
+	if err := cr.Serializer.Decode(val, &job); err != nil {
+		cr.discard(jobKey(id), err)
+		return cr.Jobs.GetJobByID(ctx, id)
+	}
+	utc(&job)
+	return &job, nil
+}
+
+func (cr *CachedJobRepository) GetJobs(ctx context.Context, page, resultsPerPage int) ([]*models.Job, error) {
+	if database.UsePrimary(ctx) {
+		return cr.Jobs.GetJobs(ctx, page, resultsPerPage)
+	}
+	key := feedKey(page, resultsPerPage)
+	val, err := cr.Cache.GetOrLoad(ctx, key, cr.FeedTTL, func(ctx context.Context) (string, []string, error) {
+		jobs, err := cr.Jobs.GetJobs(ctx, page, resultsPerPage)
+		if err != nil {
+			return "", nil, err
+		}
+		tags := make([]string, 0, len(jobs)+1)
+		tags = append(tags, feedTag)
+		for _, job := range jobs {
+			tags = append(tags, jobTag(job.ID))
+		}
+		val, err := cr.Serializer.Encode(jobs)
+		return val, tags, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*models.Job
+	if err := cr.Serializer.Decode(val, &jobs); err != nil {
+		cr.discard(key, err)
+		return cr.Jobs.GetJobs(ctx, page, resultsPerPage)
+	}
+	utc(jobs...)
+	return jobs, nil
+}
+
+// utc moves the decoded times back to UTC. msgpack decodes them in the
+// Local zone while the databases return UTC, the bodies and their ETags
+// would differ between a hit and a miss.
+func utc(jobs ...*models.Job) {
+	for _, job := range jobs {
+		job.UpdatedAt = job.UpdatedAt.UTC()
+	}
+}
+
+// discard drops an entry that doesn't decode, most likely one written by
+// another release, so the next lookup reloads it.
+func (cr *CachedJobRepository) discard(key string, err error) {
+	if err != caching.ErrStaleVersion {
+		logger.Warn("cache decode %s error:%s", key, err)
+	}
+	if _, err := cr.Cache.Delete(key); err != nil {
+		logger.Warn("cache delete %s error:%s", key, err)
+	}
+}
+
+func (cr *CachedJobRepository) invalidate(tags ...string) {
+	if _, err := cr.Cache.InvalidateTags(tags...); err != nil {
+		logger.Warn("cache invalidate tags %v error:%s", tags, err)
+	}
+}
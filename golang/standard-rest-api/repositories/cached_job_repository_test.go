@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/caching"
+	"github.com/golang/standard-rest-api/utils/database"
+)
+
+// etag hashes the JSON body of v like the controllers do
+func etag(t *testing.T, v interface{}) string {
+	t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:16])
+}
+
+func TestCachedJobsKeepUTC(t *testing.T) {
+	// msgpack decodes times in the Local zone, run on a host that isn't UTC
+	local := time.Local
+	time.Local = time.FixedZone("CEST", 2*60*60)
+	defer func() { time.Local = local }()
+
+	users := NewMemoryUserRepository()
+	jobs := NewMemoryJobRepository(users)
+	loading := caching.NewLoadingCache(caching.NewMemory(0, 0), time.Minute, ErrNotFound, time.Minute)
+	cr := NewCachedJobRepository(jobs, loading, time.Minute, time.Minute)
+	ctx := context.Background()
+
+	userID, err := users.CreateUser(ctx, "owner@example.com", "Owner", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := cr.CreateJob(ctx, "Gopher", "Description", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary, err := cr.GetJobByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := etag(t, primary)
+	// The first lookup is a miss, the second one a hit
+	for _, lookup := range []string{"miss", "hit"} {
+		job, err := cr.GetJobByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetJobByID on a %s error: %s", lookup, err)
+		}
+		if job.UpdatedAt.Location() != time.UTC {
+			t.Errorf("UpdatedAt on a %s is in %s, want UTC", lookup, job.UpdatedAt.Location())
+		}
+		if got := etag(t, job); got != want {
+			t.Errorf("ETag of the job on a %s == %s, want %s", lookup, got, want)
+		}
+	}
+
+	page, err := cr.GetJobs(database.WithPrimary(ctx), 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = etag(t, page)
+	for _, lookup := range []string{"miss", "hit"} {
+		page, err := cr.GetJobs(ctx, 1, 10)
+		if err != nil {
+			t.Fatalf("GetJobs on a %s error: %s", lookup, err)
+		}
+		if got := etag(t, page); got != want {
+			t.Errorf("ETag of the feed on a %s == %s, want %s", lookup, got, want)
+		}
+	}
+}
@@ -1,87 +1,172 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"github.com/golang/standard-rest-api/models"
+	"github.com/golang/standard-rest-api/utils/database"
+	"time"
 )
 
-func CreateJob(db *sql.DB, title, description string, userID int) (int, error) {
+type JobRepository interface {
+	CreateJob(ctx context.Context, title, description string, userID int) (int, error)
+	UpdateJob(ctx context.Context, jobID int, title, description string) error
+	DeleteJob(ctx context.Context, id int) error
+	GetJobByID(ctx context.Context, id int) (*models.Job, error)
+	GetJobs(ctx context.Context, page, resultsPerPage int) ([]*models.Job, error)
+}
+
+// SQLJobRepository stores jobs in the jobs table. It runs its queries on DB,
+// either a *sql.DB or a *sql.Tx from database.WithTx, rewritten by Dialect.
+// Read-only lookups go through Replicas when it is set.
+type SQLJobRepository struct {
+	DB       database.DBTX
+	Replicas database.ReadRouter
+	Dialect  database.Dialect
+	Timeout  time.Duration
+}
+
+func NewPostgresJobRepository(db database.DBTX, timeout time.Duration) *SQLJobRepository {
+	return &SQLJobRepository{
+		DB:      db,
+		Dialect: database.Postgres,
+		Timeout: timeout,
+	}
+}
+
+func NewSQLiteJobRepository(db database.DBTX, timeout time.Duration) *SQLJobRepository {
+	return &SQLJobRepository{
+		DB:      db,
+		Dialect: database.SQLite,
+		Timeout: timeout,
+	}
+}
+
+func (jr *SQLJobRepository) CreateJob(ctx context.Context, title, description string, userID int) (int, error) {
+	ctx, cancel := withTimeout(ctx, jr.Timeout)
+	defer cancel()
+
 	const query = `
 		insert into jobs (
 			title,
 			description,
-			user_id
-		) value (
+			user_id,
+			updated_at
+		) values (
 			$1,
 			$2,
-			$3
+			$3,
+			$4
 		) returning id
 	`
-	var id int
-	err := db.QueryRow(query, title, description, userID).Scan(&id)
-	return id, err
+	id, err := jr.Dialect.InsertReturningID(ctx, jr.DB, query, title, description, userID, now())
+	return id, queryError(ctx, jr.Dialect, err)
 }
 
-func  UpdateJob(db *sql.DB, jobID int, title, description string) error {
+func (jr *SQLJobRepository) UpdateJob(ctx context.Context, jobID int, title, description string) error {
+	ctx, cancel := withTimeout(ctx, jr.Timeout)
+	defer cancel()
+
 	const query = `
-		update set jobs
+		update jobs set
 			title = $1,
-			description = $2
-		where id = $3
+			description = $2,
+			updated_at = $3
+		where id = $4
 	`
-	_, err := db.Exec(query, title, description, jobID)
-	return err
+	_, err := jr.DB.ExecContext(ctx, jr.Dialect.Rebind(query), title, description, now(), jobID)
+	return queryError(ctx, jr.Dialect, err)
 }
 
-func DeleteJob(db *sql.DB, id int) error {
+func (jr *SQLJobRepository) DeleteJob(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, jr.Timeout)
+	defer cancel()
+
 	const query = `delete from jobs where id = $1`
-	_, err := db.Exec(query, id)
-	return err
+	_, err := jr.DB.ExecContext(ctx, jr.Dialect.Rebind(query), id)
+	return queryError(ctx, jr.Dialect, err)
 }
 
-func GetJobByID(db *sql.DB, id int) (*models.Job, error) {
+func (jr *SQLJobRepository) GetJobByID(ctx context.Context, id int) (*models.Job, error) {
+	ctx, cancel := withTimeout(ctx, jr.Timeout)
+	defer cancel()
+
 	const query = `
 		select
 			id,
 			title,
 			description,
-			user_id
+			user_id,
+			updated_at
 		from
 			jobs
 		where id = $1
 	`
 
 	var job models.Job
-	err := db.QueryRow(query, id).Scan(&job.ID, &job.Title, &job.Description, &job.UserID)
-	return &job, err
+	err := jr.reader(ctx).QueryRowContext(ctx, jr.Dialect.Rebind(query), id).Scan(&job.ID, &job.Title, &job.Description, &job.UserID, &job.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return &job, queryError(ctx, jr.Dialect, err)
 }
 
-func GetJobs(db *sql.DB, page, resultsPerPage int) ([]*models.Job, error) {
+func (jr *SQLJobRepository) GetJobs(ctx context.Context, page, resultsPerPage int) ([]*models.Job, error) {
+	ctx, cancel := withTimeout(ctx, jr.Timeout)
+	defer cancel()
+
 	const query = `
 		select
 			id,
 			title,
 			description,
-			user_id
+			user_id,
+			updated_at
 		from
 			jobs
+		order by id
 		limit $1 offset $2
 	`
 	jobs := make([]*models.Job, 0)
 	offset := (page - 1) * resultsPerPage
 
-	rows, err := db.Query(query, resultsPerPage, offset)
+	rows, err := database.Query(ctx, jr.reader(ctx), jr.Dialect.Rebind(query), resultsPerPage, offset)
 	if err != nil {
-		return nil, err
+		return nil, queryError(ctx, jr.Dialect, err)
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var job models.Job
-		err = rows.Scan(&job.ID, &job.Title, &job.Description, &job.UserID)
+		err = rows.Scan(&job.ID, &job.Title, &job.Description, &job.UserID, &job.UpdatedAt)
 		if err != nil {
-			return nil, err
+			return nil, queryError(ctx, jr.Dialect, err)
 		}
 		jobs = append(jobs, &job)
 	}
 
-	return jobs, err
+	return jobs, queryError(ctx, jr.Dialect, rows.Err())
+}
+
+// now is the updated_at of a mutation, stored in UTC and truncated to the
+// microseconds both databases keep so a cached job matches the stored one.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// WithTx returns a copy of the repository running its queries in tx with
+// the same instrumentation. The reads stay in the transaction too instead
+// of going to a replica.
+func (jr *SQLJobRepository) WithTx(tx *sql.Tx) *SQLJobRepository {
+	c := *jr
+	c.DB = database.Instrument(tx, database.Observers(jr.DB)...)
+	c.Replicas = nil
+	return &c
+}
+
+// reader is the database of the read-only queries
+func (jr *SQLJobRepository) reader(ctx context.Context) database.DBTX {
+	if jr.Replicas == nil {
+		return jr.DB
+	}
+	return jr.Replicas.Reader(ctx)
 }
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/standard-rest-api/models"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// MemoryJobRepository keeps jobs in process memory, it is meant for
+// tests and local demos and loses everything on restart.
+type MemoryJobRepository struct {
+	nextID int
+	jobs   map[int]*models.Job
+	// users stands in for the jobs_user_id_fkey constraint
+	users *MemoryUserRepository
+	sync.RWMutex
+}
+
+func NewMemoryJobRepository(users *MemoryUserRepository) *MemoryJobRepository {
+	return &MemoryJobRepository{
+		nextID: 1,
+		jobs:   make(map[int]*models.Job),
+		users:  users,
+	}
+}
+
+func (jr *MemoryJobRepository) CreateJob(ctx context.Context, title, description string, userID int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if !jr.users.exists(userID) {
+		return 0, &ConstraintError{
+			Constraint: "jobs_user_id_fkey",
+			Kind:       ErrInvalidReference,
+			Err:        errors.New("no user " + strconv.Itoa(userID)),
+		}
+	}
+	jr.Lock()
+	defer jr.Unlock()
+	id := jr.nextID
+	jr.nextID++
+	jr.jobs[id] = &models.Job{
+		ID:          id,
+		Title:       title,
+		Description: description,
+		UserID:      strconv.Itoa(userID),
+		UpdatedAt:   now(),
+	}
+	return id, nil
+}
+
+func (jr *MemoryJobRepository) UpdateJob(ctx context.Context, jobID int, title, description string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	jr.Lock()
+	defer jr.Unlock()
+	if job, ok := jr.jobs[jobID]; ok {
+		job.Title = title
+		job.Description = description
+		job.UpdatedAt = now()
+	}
+	return nil
+}
+
+func (jr *MemoryJobRepository) DeleteJob(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	jr.Lock()
+	defer jr.Unlock()
+	delete(jr.jobs, id)
+	return nil
+}
+
+func (jr *MemoryJobRepository) GetJobByID(ctx context.Context, id int) (*models.Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	jr.RLock()
+	defer jr.RUnlock()
+	job, ok := jr.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	j := *job
+	return &j, nil
+}
+
+// GetJobs pages through the jobs in id order like the SQL implementations
+func (jr *MemoryJobRepository) GetJobs(ctx context.Context, page, resultsPerPage int) ([]*models.Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	jr.RLock()
+	defer jr.RUnlock()
+	ids := make([]int, 0, len(jr.jobs))
+	for id := range jr.jobs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	jobs := make([]*models.Job, 0)
+	offset := (page - 1) * resultsPerPage
+	if offset < 0 {
+		offset = 0
+	}
+	for i := offset; i < len(ids) && len(jobs) < resultsPerPage; i++ {
+		j := *jr.jobs[ids[i]]
+		jobs = append(jobs, &j)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/standard-rest-api/models"
+	"github.com/golang/standard-rest-api/utils/crypto"
+	"sync"
+)
+
+// MemoryUserRepository keeps users in process memory, it is meant for
+// tests and local demos and loses everything on restart.
+type MemoryUserRepository struct {
+	nextID  int
+	users   map[int]*models.PrivateUserDetails
+	profile map[int]*models.User
+	byEmail map[string]int
+	sync.RWMutex
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		nextID:  1,
+		users:   make(map[int]*models.PrivateUserDetails),
+		profile: make(map[int]*models.User),
+		byEmail: make(map[string]int),
+	}
+}
+
+func (ur *MemoryUserRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ur.RLock()
+	defer ur.RUnlock()
+	u, ok := ur.profile[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user := *u
+	return &user, nil
+}
+
+func (ur *MemoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ur.RLock()
+	defer ur.RUnlock()
+	id, ok := ur.byEmail[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user := *ur.profile[id]
+	return &user, nil
+}
+
+func (ur *MemoryUserRepository) GetPrivateUserDetailByEmail(ctx context.Context, email string) (*models.PrivateUserDetails, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ur.RLock()
+	defer ur.RUnlock()
+	id, ok := ur.byEmail[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	u := *ur.users[id]
+	return &u, nil
+}
+
+// exists reports whether the user with the id was created
+func (ur *MemoryUserRepository) exists(id int) bool {
+	ur.RLock()
+	defer ur.RUnlock()
+	_, ok := ur.users[id]
+	return ok
+}
+
+func (ur *MemoryUserRepository) CreateUser(ctx context.Context, email, name, password string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	salt := crypto.GenerateSalt()
+	hashPassword := crypto.HashPassword(password, salt)
+
+	ur.Lock()
+	defer ur.Unlock()
+	if _, ok := ur.byEmail[email]; ok {
+		return 0, &ConstraintError{
+			Constraint: "users_email_key",
+			Kind:       ErrConflict,
+			Err:        errors.New("duplicate email " + email),
+		}
+	}
+	id := ur.nextID
+	ur.nextID++
+	ur.users[id] = &models.PrivateUserDetails{
+		ID:       id,
+		Password: hashPassword,
+		Salt:     salt,
+	}
+	ur.profile[id] = &models.User{
+		ID:    id,
+		Email: email,
+		Name:  name,
+	}
+	ur.byEmail[email] = id
+	return id, nil
+}
+
+func (ur *MemoryUserRepository) UpdatePassword(ctx context.Context, id int, password string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	salt := crypto.GenerateSalt()
+	hashPassword := crypto.HashPassword(password, salt)
+
+	ur.Lock()
+	defer ur.Unlock()
+	if u, ok := ur.users[id]; ok {
+		u.Password = hashPassword
+		u.Salt = salt
+	}
+	return nil
+}
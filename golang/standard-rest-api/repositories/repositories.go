@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/database"
+)
+
+var (
+	// ErrNotFound is returned by every repository implementation when the
+	// requested record doesn't exist.
+	ErrNotFound = errors.New("repositories: record not found")
+	// ErrConflict is returned when a record with the same unique key exists
+	ErrConflict = errors.New("repositories: record already exists")
+	// ErrInvalidReference is returned when a record refers to a missing one
+	ErrInvalidReference = errors.New("repositories: referenced record not found")
+)
+
+// ConstraintError is a unique or foreign key violation, errors.Is matches
+// it with ErrConflict or ErrInvalidReference.
+type ConstraintError struct {
+	Constraint string
+	Kind       error
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Kind.Error() + " (" + e.Constraint + "): " + e.Err.Error()
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Kind
+}
+
+// DefaultQueryTimeout bounds a query when the repository isn't given a timeout
+const DefaultQueryTimeout = 5 * time.Second
+
+// withTimeout bounds ctx by the default query timeout of a repository
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryError reports the context error when the query failed because ctx
+// was canceled or timed out, drivers return their own error in that case.
+// Constraint violations become a *ConstraintError.
+func queryError(ctx context.Context, dialect database.Dialect, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	switch violation, constraint := dialect.Violation(err); violation {
+	case database.UniqueViolation:
+		return &ConstraintError{Constraint: constraint, Kind: ErrConflict, Err: err}
+	case database.ForeignKeyViolation:
+		return &ConstraintError{Constraint: constraint, Kind: ErrInvalidReference, Err: err}
+	}
+	return err
+}
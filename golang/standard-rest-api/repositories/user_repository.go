@@ -1,12 +1,53 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
-	"github.com/golang/standard-rest-api/utils/crypto"
 	"github.com/golang/standard-rest-api/models"
+	"github.com/golang/standard-rest-api/utils/crypto"
+	"github.com/golang/standard-rest-api/utils/database"
+	"time"
 )
 
-func GetUserByID(db *sql.DB, id int) (*models.User, error) {
+type UserRepository interface {
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetPrivateUserDetailByEmail(ctx context.Context, email string) (*models.PrivateUserDetails, error)
+	CreateUser(ctx context.Context, email, name, password string) (int, error)
+	// UpdatePassword stores a new salt and hash of password for the user
+	UpdatePassword(ctx context.Context, id int, password string) error
+}
+
+// SQLUserRepository stores users in the users table. It runs its queries on DB,
+// either a *sql.DB or a *sql.Tx from database.WithTx, rewritten by Dialect.
+// Read-only lookups go through Replicas when it is set.
+type SQLUserRepository struct {
+	DB       database.DBTX
+	Replicas database.ReadRouter
+	Dialect  database.Dialect
+	Timeout  time.Duration
+}
+
+func NewPostgresUserRepository(db database.DBTX, timeout time.Duration) *SQLUserRepository {
+	return &SQLUserRepository{
+		DB:      db,
+		Dialect: database.Postgres,
+		Timeout: timeout,
+	}
+}
+
+func NewSQLiteUserRepository(db database.DBTX, timeout time.Duration) *SQLUserRepository {
+	return &SQLUserRepository{
+		DB:      db,
+		Dialect: database.SQLite,
+		Timeout: timeout,
+	}
+}
+
+func (ur *SQLUserRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, ur.Timeout)
+	defer cancel()
+
 	const query = `
 		select
 			id,
@@ -18,11 +59,17 @@ func GetUserByID(db *sql.DB, id int) (*models.User, error) {
 			id = $1
 	`
 	var user models.User
-	err := db.QueryRow(query, id).Scan(&user.ID, &user.Email, &user.Name)
-	return &user, err
+	err := ur.DB.QueryRowContext(ctx, ur.Dialect.Rebind(query), id).Scan(&user.ID, &user.Email, &user.Name)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return &user, queryError(ctx, ur.Dialect, err)
 }
 
-func GetUserByEmail(db *sql.DB, email string) (*models.User, error) {
+func (ur *SQLUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, ur.Timeout)
+	defer cancel()
+
 	const query = `
 		select
 			id,
@@ -34,11 +81,17 @@ func GetUserByEmail(db *sql.DB, email string) (*models.User, error) {
 			email = $1
 	`
 	var user models.User
-	err := db.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.Name)
-	return &user, err
+	err := ur.reader(ctx).QueryRowContext(ctx, ur.Dialect.Rebind(query), email).Scan(&user.ID, &user.Email, &user.Name)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return &user, queryError(ctx, ur.Dialect, err)
 }
 
-func GetPrivateUserDetailByEmail(db *sql.DB, email string) (*models.PrivateUserDetails, error) {
+func (ur *SQLUserRepository) GetPrivateUserDetailByEmail(ctx context.Context, email string) (*models.PrivateUserDetails, error) {
+	ctx, cancel := withTimeout(ctx, ur.Timeout)
+	defer cancel()
+
 	const query = `
 		select
 			id,
@@ -50,15 +103,21 @@ func GetPrivateUserDetailByEmail(db *sql.DB, email string) (*models.PrivateUserD
 			email = $1
 	`
 	var u models.PrivateUserDetails
-	err := db.QueryRow(query, email).Scan(&u.ID, &u.Password, &u.Salt)
-	return &u, err
+	err := ur.DB.QueryRowContext(ctx, ur.Dialect.Rebind(query), email).Scan(&u.ID, &u.Password, &u.Salt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return &u, queryError(ctx, ur.Dialect, err)
 }
 
-func CreateUser(db *sql.DB, email, user, password string) (int, error) {
+func (ur *SQLUserRepository) CreateUser(ctx context.Context, email, name, password string) (int, error) {
+	ctx, cancel := withTimeout(ctx, ur.Timeout)
+	defer cancel()
+
 	const query = `
 		insert into users(
 			email,
-			user,
+			name,
 			password,
 			salt
 		) values (
@@ -70,7 +129,40 @@ func CreateUser(db *sql.DB, email, user, password string) (int, error) {
 	`
 	salt := crypto.GenerateSalt()
 	hashPassword := crypto.HashPassword(password, salt)
-	var id int
-	err := db.QueryRow(query, email, user, password, hashPassword).Scan(&id)
-	return id, err
+	id, err := ur.Dialect.InsertReturningID(ctx, ur.DB, query, email, name, hashPassword, salt)
+	return id, queryError(ctx, ur.Dialect, err)
+}
+
+func (ur *SQLUserRepository) UpdatePassword(ctx context.Context, id int, password string) error {
+	ctx, cancel := withTimeout(ctx, ur.Timeout)
+	defer cancel()
+
+	const query = `
+		update users set
+			password = $1,
+			salt = $2
+		where id = $3
+	`
+	salt := crypto.GenerateSalt()
+	hashPassword := crypto.HashPassword(password, salt)
+	_, err := ur.DB.ExecContext(ctx, ur.Dialect.Rebind(query), hashPassword, salt, id)
+	return queryError(ctx, ur.Dialect, err)
+}
+
+// WithTx returns a copy of the repository running its queries in tx with
+// the same instrumentation. The reads stay in the transaction too instead
+// of going to a replica.
+func (ur *SQLUserRepository) WithTx(tx *sql.Tx) *SQLUserRepository {
+	c := *ur
+	c.DB = database.Instrument(tx, database.Observers(ur.DB)...)
+	c.Replicas = nil
+	return &c
+}
+
+// reader is the database of the read-only queries
+func (ur *SQLUserRepository) reader(ctx context.Context) database.DBTX {
+	if ur.Replicas == nil {
+		return ur.DB
+	}
+	return ur.Replicas.Reader(ctx)
 }
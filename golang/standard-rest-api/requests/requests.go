@@ -1,22 +1,22 @@
 package requests
 
 type RegisterRequest struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
-	Password string `json:"password"`
+	Email string `json:"email" xml:"email"`
+	Name  string `json:"name" xml:"name"`
+	Password string `json:"password" xml:"password"`
 }
 
 type LoginRequest struct {
-	Email string `json:"email"`
-	Password string `json:"password"`
+	Email string `json:"email" xml:"email"`
+	Password string `json:"password" xml:"password"`
 }
 
 type CreateJobRequest struct {
-	Title string `json:"tile"`
-	Description string `json:"description"`
+	Title string `json:"title" xml:"title"`
+	Description string `json:"description" xml:"description"`
 }
 
 type UpdateJobRequest struct {
-	Title string `json:"title"`
-	Description string `json:"description"`
-}
\ No newline at end of file
+	Title string `json:"title" xml:"title"`
+	Description string `json:"description" xml:"description"`
+}
@@ -0,0 +1,11 @@
+package responses
+
+type TokenResponse struct {
+	Token string `json:"token" xml:"token"`
+}
+
+// RegisterResponse keeps the capitalized key /register has always sent,
+// /login sends a TokenResponse
+type RegisterResponse struct {
+	Token string `json:"Token" xml:"token"`
+}
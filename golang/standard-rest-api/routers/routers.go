@@ -3,13 +3,23 @@ package routers
 import (
 	"net/http"
 	"github.com/golang/standard-rest-api/controllers"
+	"github.com/golang/standard-rest-api/middlewares"
+	"github.com/golang/standard-rest-api/utils/metrics"
 )
 
-func CreateRouters(mux *http.ServeMux, uc *controllers.UserController, jc *controllers.JobController) {
-	mux.HandleFunc("/register", uc.Register)
-	mux.HandleFunc("/login", uc.Login)
+func CreateRouters(mux *http.ServeMux, uc *controllers.UserController, jc *controllers.JobController, hc *controllers.HealthController) {
+	handle(mux, "/healthz", hc.Healthz)
+	handle(mux, "/readyz", hc.Readyz)
+	handle(mux, "/metrics", metrics.Handler().ServeHTTP)
 
-	mux.HandleFunc("/job", jc.Create)
-	mux.HandleFunc("/job/", jc.Job)
-	mux.HandleFunc("/feed", jc.Feed)
+	handle(mux, "/register", uc.Register)
+	handle(mux, "/login", uc.Login)
+
+	handle(mux, "/job", jc.Create)
+	handle(mux, "/job/", jc.Job)
+	handle(mux, "/feed", jc.Feed)
+}
+
+func handle(mux *http.ServeMux, pattern string, h http.HandlerFunc) {
+	mux.HandleFunc(pattern, middlewares.Metrics(pattern, middlewares.Tracing(pattern, h)))
 }
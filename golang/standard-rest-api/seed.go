@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+
+	"github.com/golang/standard-rest-api/database/fixtures"
+	"github.com/golang/standard-rest-api/database/seed"
+	"github.com/golang/standard-rest-api/repositories"
+	"github.com/golang/standard-rest-api/utils/database"
+)
+
+// runSeed implements the seed command, it creates random users and jobs
+// and then loads the fixture files given as arguments, all in one
+// transaction so a failure leaves the database untouched.
+func runSeed(db *sql.DB, users *repositories.SQLUserRepository, jobs *repositories.SQLJobRepository, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	opts := &seed.Options{}
+	fs.IntVar(&opts.Users, "users", 10, "number of users to create")
+	fs.IntVar(&opts.Jobs, "jobs", 50, "number of jobs to create")
+	fs.Int64Var(&opts.Seed, "seed", 1, "random seed, the same seed creates the same data")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	var (
+		res *seed.Result
+		l   *fixtures.Loader
+	)
+	err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		txUsers, txJobs := users.WithTx(tx), jobs.WithTx(tx)
+		var err error
+		if res, err = seed.Run(ctx, txUsers, txJobs, opts); err != nil {
+			return err
+		}
+		l = fixtures.NewLoader(txUsers, txJobs)
+		return l.LoadFiles(ctx, fs.Args()...)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Seeded %d users and %d jobs, the password of every user is %q",
+		len(res.UserIDs), len(res.JobIDs), seed.DefaultPassword)
+	if fs.NArg() > 0 {
+		log.Printf("Loaded %d users and %d jobs from %d fixture files", len(l.UserIDs), len(l.JobIDs), fs.NArg())
+	}
+	return nil
+}
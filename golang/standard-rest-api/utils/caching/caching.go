@@ -1,13 +1,49 @@
 package caching
 
 import (
+	"errors"
 	"github.com/go-redis/redis"
+	"github.com/golang/standard-rest-api/utils/metrics"
 	"time"
 )
 
+var (
+	cacheHits = metrics.NewCounter("cache_hits_total", "Number of cache lookups that found the key.", "backend")
+	cacheMisses = metrics.NewCounter("cache_misses_total", "Number of cache lookups that missed the key.", "backend")
+	cacheErrors = metrics.NewCounter("cache_errors_total", "Number of failed cache operations.", "backend", "op")
+)
+
+// ErrMiss is returned when the key doesn't exist in the cache
+var ErrMiss = errors.New("caching: cache miss")
+
+// NoExpiration is the TTL of a key that never expires
+const NoExpiration time.Duration = -1
+
 type Cache interface {
 	Get(key string) (string, error)
 	Set(key, value string, expiration time.Duration) error
+	// SetNX sets the key only when it doesn't exist yet and reports whether it did
+	SetNX(key, value string, expiration time.Duration) (bool, error)
+	// Delete removes the keys and returns how many of them existed
+	Delete(keys ...string) (int64, error)
+	Exists(key string) (bool, error)
+	// TTL returns the remaining time to live of the key, NoExpiration for a
+	// persistent key and ErrMiss when the key doesn't exist
+	TTL(key string) (time.Duration, error)
+	// Expire sets the expiration of an existing key and reports whether it exists
+	Expire(key string, expiration time.Duration) (bool, error)
+	Incr(key string) (int64, error)
+	IncrBy(key string, value int64) (int64, error)
+	// MGet returns the values of the keys that were found, missing keys are
+	// left out of the map
+	MGet(keys ...string) (map[string]string, error)
+	MSet(values map[string]string, expiration time.Duration) error
+	// SetWithTags sets the key and associates it with the tags
+	SetWithTags(key, value string, expiration time.Duration, tags ...string) error
+	// InvalidateTags atomically deletes every key associated with the tags
+	// and returns the deleted keys
+	InvalidateTags(tags ...string) ([]string, error)
+	Ping() error
 }
 
 type Redis struct {
@@ -22,10 +58,119 @@ func Connect(addr, password string, db int) *redis.Client {
 	})
 }
 
+// failed counts the error of the op, it returns err so it can wrap a return
+func (r *Redis) failed(op string, err error) error {
+	if err != nil {
+		cacheErrors.Inc("redis", op)
+	}
+	return err
+}
+
 func (r *Redis) Get(key string) (string, error) {
-	return r.Client.Get(key).Result()
+	val, err := r.Client.Get(key).Result()
+	switch {
+	case err == redis.Nil:
+		cacheMisses.Inc("redis")
+		return "", ErrMiss
+	case err != nil:
+		cacheErrors.Inc("redis", "get")
+	default:
+		cacheHits.Inc("redis")
+	}
+	return val, err
 }
 
 func (r *Redis) Set(key, value string, expiration time.Duration) error {
-	return r.Client.Set(key, value, expiration).Err()
-}
\ No newline at end of file
+	return r.failed("set", r.Client.Set(key, value, expiration).Err())
+}
+
+func (r *Redis) SetNX(key, value string, expiration time.Duration) (bool, error) {
+	ok, err := r.Client.SetNX(key, value, expiration).Result()
+	return ok, r.failed("setnx", err)
+}
+
+func (r *Redis) Delete(keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	n, err := r.Client.Del(keys...).Result()
+	return n, r.failed("delete", err)
+}
+
+func (r *Redis) Exists(key string) (bool, error) {
+	n, err := r.Client.Exists(key).Result()
+	return n > 0, r.failed("exists", err)
+}
+
+func (r *Redis) TTL(key string) (time.Duration, error) {
+	ttl, err := r.Client.TTL(key).Result()
+	if err != nil {
+		return 0, r.failed("ttl", err)
+	}
+	// redis answers -2 for a missing key and -1 for a key without expiration
+	switch ttl {
+	case -2 * time.Second:
+		return 0, ErrMiss
+	case -1 * time.Second:
+		return NoExpiration, nil
+	}
+	return ttl, nil
+}
+
+func (r *Redis) Expire(key string, expiration time.Duration) (bool, error) {
+	ok, err := r.Client.Expire(key, expiration).Result()
+	return ok, r.failed("expire", err)
+}
+
+func (r *Redis) Incr(key string) (int64, error) {
+	return r.IncrBy(key, 1)
+}
+
+func (r *Redis) IncrBy(key string, value int64) (int64, error) {
+	n, err := r.Client.IncrBy(key, value).Result()
+	return n, r.failed("incr", err)
+}
+
+func (r *Redis) MGet(keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	vals, err := r.Client.MGet(keys...).Result()
+	if err != nil {
+		return nil, r.failed("mget", err)
+	}
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			cacheMisses.Inc("redis")
+			continue
+		}
+		cacheHits.Inc("redis")
+		values[keys[i]] = s
+	}
+	return values, nil
+}
+
+// MSet sets all the values in one round trip. MSET has no expiration so the
+// keys are set one by one inside a MULTI/EXEC pipeline instead.
+func (r *Redis) MSet(values map[string]string, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	_, err := r.Client.TxPipelined(func(pipe redis.Pipeliner) error {
+		for k, v := range values {
+			pipe.Set(k, v, expiration)
+		}
+		return nil
+	})
+	return r.failed("mset", err)
+}
+
+func (r *Redis) Ping() error {
+	return r.Client.Ping().Err()
+}
+
+func (r *Redis) Close() error {
+	return r.Client.Close()
+}
@@ -0,0 +1,74 @@
+package caching
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// Codec serializes the values of GetAs and SetAs. The ID is written in the
+// payload so an entry is always decoded with the codec that encoded it.
+type Codec interface {
+	ID() byte
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte {
+	return 1
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// gobCodec is the most compact for Go types but the payloads can only be
+// read by Go, and a renamed type is a decode error.
+type gobCodec struct{}
+
+func (gobCodec) ID() byte {
+	return 2
+}
+
+func (gobCodec) Encode(w io.Writer, v interface{}) error {
+	return gob.NewEncoder(w).Encode(v)
+}
+
+func (gobCodec) Decode(r io.Reader, v interface{}) error {
+	return gob.NewDecoder(r).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte {
+	return 3
+}
+
+// Encode reuses the json tags like the render package
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	return msgpack.NewEncoder(w).UseJSONTag(true).Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	return msgpack.NewDecoder(r).UseJSONTag(true).Decode(v)
+}
+
+var (
+	JSON        Codec = jsonCodec{}
+	Gob         Codec = gobCodec{}
+	MessagePack Codec = msgpackCodec{}
+)
+
+var codecs = map[byte]Codec{
+	JSON.ID():        JSON,
+	Gob.ID():         Gob,
+	MessagePack.ID(): MessagePack,
+}
@@ -0,0 +1,125 @@
+package caching
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/golang/standard-rest-api/logger"
+)
+
+// Invalidator broadcasts the keys changed by this instance so the other
+// instances can evict them from their local cache.
+type Invalidator interface {
+	Publish(keys ...string) error
+	// Listen calls evict with the keys changed by the other instances until
+	// Close. evict gets nil when messages may have been lost and the whole
+	// local cache should be dropped.
+	Listen(evict func(keys []string))
+	Close() error
+}
+
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+// RedisInvalidator sends the invalidations over a redis pub/sub channel
+type RedisInvalidator struct {
+	Client  *redis.Client
+	Channel string
+
+	// id tells our own messages apart, they were already applied locally
+	id     string
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	closed chan struct{}
+}
+
+func NewRedisInvalidator(client *redis.Client, channel string) *RedisInvalidator {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &RedisInvalidator{
+		Client:  client,
+		Channel: channel,
+		id:      hex.EncodeToString(b),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (ri *RedisInvalidator) Publish(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(invalidation{Origin: ri.id, Keys: keys})
+	if err != nil {
+		return err
+	}
+	if err := ri.Client.Publish(ri.Channel, data).Err(); err != nil {
+		cacheErrors.Inc("redis", "publish")
+		return err
+	}
+	return nil
+}
+
+func (ri *RedisInvalidator) Listen(evict func(keys []string)) {
+	ri.mu.Lock()
+	ri.pubsub = ri.Client.Subscribe(ri.Channel)
+	pubsub := ri.pubsub
+	ri.mu.Unlock()
+	go ri.receive(pubsub, evict)
+}
+
+func (ri *RedisInvalidator) receive(pubsub *redis.PubSub, evict func(keys []string)) {
+	subscribed := false
+	for {
+		msg, err := pubsub.Receive()
+		if err != nil {
+			select {
+			case <-ri.closed:
+				return
+			default:
+			}
+			// The connection is reestablished by the next Receive, whatever
+			// was published meanwhile is lost
+			logger.Warn("cache invalidation channel %s error:%s", ri.Channel, err)
+			evict(nil)
+			time.Sleep(time.Second)
+			continue
+		}
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if subscribed {
+				evict(nil)
+			}
+			subscribed = true
+		case *redis.Message:
+			var inv invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+				logger.Warn("cache invalidation decode error:%s", err)
+				continue
+			}
+			if inv.Origin != ri.id {
+				evict(inv.Keys)
+			}
+		}
+	}
+}
+
+func (ri *RedisInvalidator) Close() error {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	select {
+	case <-ri.closed:
+		return nil
+	default:
+	}
+	close(ri.closed)
+	if ri.pubsub == nil {
+		return nil
+	}
+	return ri.pubsub.Close()
+}
@@ -0,0 +1,235 @@
+package caching
+
+import (
+	"time"
+
+	"github.com/golang/standard-rest-api/logger"
+)
+
+// Layered keeps a local copy of the keys read from L2 in L1. Writes go to L2
+// first, then to L1, and are broadcast by Invalidator so the other instances
+// evict their copy. An L1 entry lives at most L1TTL, which bounds how stale it
+// gets when an invalidation is lost, and never longer than the key in L2.
+type Layered struct {
+	L1          *Memory
+	L2          Cache
+	L1TTL       time.Duration
+	Invalidator Invalidator
+}
+
+// NewLayered returns a Layered cache listening to the invalidations of the
+// other instances, invalidator may be nil for a single instance.
+func NewLayered(l1 *Memory, l2 Cache, l1TTL time.Duration, invalidator Invalidator) *Layered {
+	c := &Layered{
+		L1:          l1,
+		L2:          l2,
+		L1TTL:       l1TTL,
+		Invalidator: invalidator,
+	}
+	if invalidator != nil {
+		invalidator.Listen(c.evict)
+	}
+	return c
+}
+
+func (c *Layered) evict(keys []string) {
+	if keys == nil {
+		c.L1.Flush()
+		return
+	}
+	c.L1.Delete(keys...)
+}
+
+// localTTL caps expiration to L1TTL
+func (c *Layered) localTTL(expiration time.Duration) time.Duration {
+	if expiration <= 0 || expiration > c.L1TTL {
+		return c.L1TTL
+	}
+	return expiration
+}
+
+// fill copies a value read from L2 into L1 for no longer than the key has
+// left in L2, a copy outliving it would serve an expired value, e.g. a
+// negative entry of LoadingCache. A key whose TTL can't be read isn't
+// copied.
+func (c *Layered) fill(key, value string) {
+	ttl, err := c.L2.TTL(key)
+	if err != nil || (ttl <= 0 && ttl != NoExpiration) {
+		return
+	}
+	c.L1.Set(key, value, c.localTTL(ttl))
+}
+
+// changed drops the keys from L1 and tells the other instances
+func (c *Layered) changed(keys ...string) {
+	c.L1.Delete(keys...)
+	c.publish(keys...)
+}
+
+func (c *Layered) publish(keys ...string) {
+	if c.Invalidator == nil {
+		return
+	}
+	if err := c.Invalidator.Publish(keys...); err != nil {
+		logger.Warn("cache invalidation publish error:%s", err)
+	}
+}
+
+func (c *Layered) Get(key string) (string, error) {
+	if val, err := c.L1.Get(key); err == nil {
+		return val, nil
+	}
+	val, err := c.L2.Get(key)
+	if err != nil {
+		return "", err
+	}
+	c.fill(key, val)
+	return val, nil
+}
+
+func (c *Layered) Set(key, value string, expiration time.Duration) error {
+	if err := c.L2.Set(key, value, expiration); err != nil {
+		c.L1.Delete(key)
+		return err
+	}
+	c.L1.Set(key, value, c.localTTL(expiration))
+	c.publish(key)
+	return nil
+}
+
+func (c *Layered) SetNX(key, value string, expiration time.Duration) (bool, error) {
+	ok, err := c.L2.SetNX(key, value, expiration)
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.L1.Set(key, value, c.localTTL(expiration))
+	c.publish(key)
+	return true, nil
+}
+
+func (c *Layered) Delete(keys ...string) (int64, error) {
+	n, err := c.L2.Delete(keys...)
+	c.changed(keys...)
+	return n, err
+}
+
+// Exists and TTL are answered by L2, L1 doesn't know the real expiration
+func (c *Layered) Exists(key string) (bool, error) {
+	return c.L2.Exists(key)
+}
+
+func (c *Layered) TTL(key string) (time.Duration, error) {
+	return c.L2.TTL(key)
+}
+
+func (c *Layered) Expire(key string, expiration time.Duration) (bool, error) {
+	ok, err := c.L2.Expire(key, expiration)
+	c.changed(key)
+	return ok, err
+}
+
+func (c *Layered) Incr(key string) (int64, error) {
+	return c.IncrBy(key, 1)
+}
+
+func (c *Layered) IncrBy(key string, value int64) (int64, error) {
+	n, err := c.L2.IncrBy(key, value)
+	c.changed(key)
+	return n, err
+}
+
+func (c *Layered) MGet(keys ...string) (map[string]string, error) {
+	values, _ := c.L1.MGet(keys...)
+	if len(values) == len(keys) {
+		return values, nil
+	}
+	missing := make([]string, 0, len(keys)-len(values))
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	remote, err := c.L2.MGet(missing...)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range remote {
+		c.fill(k, v)
+		values[k] = v
+	}
+	return values, nil
+}
+
+func (c *Layered) MSet(values map[string]string, expiration time.Duration) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	if err := c.L2.MSet(values, expiration); err != nil {
+		c.L1.Delete(keys...)
+		return err
+	}
+	c.L1.MSet(values, c.localTTL(expiration))
+	c.publish(keys...)
+	return nil
+}
+
+func (c *Layered) SetWithTags(key, value string, expiration time.Duration, tags ...string) error {
+	if err := c.L2.SetWithTags(key, value, expiration, tags...); err != nil {
+		c.L1.Delete(key)
+		return err
+	}
+	c.L1.SetWithTags(key, value, c.localTTL(expiration), tags...)
+	c.publish(key)
+	return nil
+}
+
+// InvalidateTags invalidates the tags in L2 and evicts the keys it deleted
+// everywhere. The local tags are invalidated too, L1 may hold tagged keys
+// that already expired from L2.
+func (c *Layered) InvalidateTags(tags ...string) ([]string, error) {
+	deleted, err := c.L2.InvalidateTags(tags...)
+	c.L1.InvalidateTags(tags...)
+	if len(deleted) > 0 {
+		c.changed(deleted...)
+	}
+	return deleted, err
+}
+
+func (c *Layered) Ping() error {
+	return c.L2.Ping()
+}
+
+// Close stops listening to the invalidations and the L1 janitor, L2 is left
+// to its owner.
+func (c *Layered) Close() error {
+	var err error
+	if c.Invalidator != nil {
+		err = c.Invalidator.Close()
+	}
+	c.L1.Close()
+	return err
+}
+
+// The locks live in L2 only, a local copy would let two instances hold the
+// same lock.
+func (c *Layered) AcquireLock(key, token string, ttl time.Duration) (bool, error) {
+	if store, ok := c.L2.(LockStore); ok {
+		return store.AcquireLock(key, token, ttl)
+	}
+	return false, errNoLocks
+}
+
+func (c *Layered) ReleaseLock(key, token string) (bool, error) {
+	if store, ok := c.L2.(LockStore); ok {
+		return store.ReleaseLock(key, token)
+	}
+	return false, errNoLocks
+}
+
+func (c *Layered) ExtendLock(key, token string, ttl time.Duration) (bool, error) {
+	if store, ok := c.L2.(LockStore); ok {
+		return store.ExtendLock(key, token, ttl)
+	}
+	return false, errNoLocks
+}
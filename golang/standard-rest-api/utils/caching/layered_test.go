@@ -0,0 +1,179 @@
+package caching
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBus is an Invalidator delivering to the other listeners of the bus
+// right away, like a redis channel without the network.
+type memoryBus struct {
+	mu        sync.Mutex
+	listeners []*busListener
+}
+
+type busListener struct {
+	bus   *memoryBus
+	evict func(keys []string)
+}
+
+func (b *memoryBus) join() *busListener {
+	return &busListener{bus: b}
+}
+
+func (l *busListener) Publish(keys ...string) error {
+	l.bus.mu.Lock()
+	defer l.bus.mu.Unlock()
+	for _, other := range l.bus.listeners {
+		if other != l {
+			other.evict(keys)
+		}
+	}
+	return nil
+}
+
+func (l *busListener) Listen(evict func(keys []string)) {
+	l.bus.mu.Lock()
+	defer l.bus.mu.Unlock()
+	l.evict = evict
+	l.bus.listeners = append(l.bus.listeners, l)
+}
+
+func (l *busListener) Close() error {
+	return nil
+}
+
+func TestLayeredL1BoundByL2TTL(t *testing.T) {
+	l2 := NewMemory(0, 0)
+	c := NewLayered(NewMemory(0, 0), l2, time.Minute, nil)
+
+	l2.Set("short", "1", 50*time.Millisecond)
+	l2.Set("forever", "2", NoExpiration)
+	if _, err := c.Get("short"); err != nil {
+		t.Fatalf("Get(short) error: %s", err)
+	}
+	if _, err := c.MGet("forever"); err != nil {
+		t.Fatalf("MGet(forever) error: %s", err)
+	}
+
+	if ttl, err := c.L1.TTL("short"); err != nil || ttl > 50*time.Millisecond {
+		t.Errorf("L1 TTL of short == %s, %v, want at most 50ms", ttl, err)
+	}
+	if ttl, err := c.L1.TTL("forever"); err != nil || ttl > time.Minute || ttl < 50*time.Second {
+		t.Errorf("L1 TTL of forever == %s, %v, want about L1TTL", ttl, err)
+	}
+
+	// The local copy doesn't outlive the key in L2
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.Get("short"); err != ErrMiss {
+		t.Errorf("Get(short) after the L2 expiry error == %v, want ErrMiss", err)
+	}
+}
+
+func TestLayeredNegativeEntryNotServedStale(t *testing.T) {
+	c := NewLayered(NewMemory(0, 0), NewMemory(0, 0), time.Minute, nil)
+	lc := NewLoadingCache(c, time.Minute, errNotFound, 30*time.Millisecond)
+	calls := 0
+	loader := func(ctx context.Context) (string, []string, error) {
+		if calls++; calls == 1 {
+			return "", nil, errNotFound
+		}
+		return "found", nil, nil
+	}
+	ctx := context.Background()
+
+	if _, err := lc.GetOrLoad(ctx, "key", time.Minute, loader); err != errNotFound {
+		t.Fatalf("first lookup error == %v, want errNotFound", err)
+	}
+	// Drop the copy written by the load, the next lookup fills L1 from L2
+	c.L1.Flush()
+	if _, err := lc.GetOrLoad(ctx, "key", time.Minute, loader); err != errNotFound {
+		t.Fatalf("second lookup error == %v, want errNotFound", err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if v, err := lc.GetOrLoad(ctx, "key", time.Minute, loader); err != nil || v != "found" {
+		t.Errorf("lookup after the negative TTL == %q, %v, want \"found\"", v, err)
+	}
+}
+
+func TestLayeredInvalidation(t *testing.T) {
+	bus := &memoryBus{}
+	l2 := NewMemory(0, 0)
+	a := NewLayered(NewMemory(0, 0), l2, time.Minute, bus.join())
+	b := NewLayered(NewMemory(0, 0), l2, time.Minute, bus.join())
+
+	a.Set("key", "1", time.Minute)
+	if v, _ := b.Get("key"); v != "1" {
+		t.Fatalf("b.Get(key) == %q, want \"1\"", v)
+	}
+
+	// b's local copy is evicted by a's write
+	a.Set("key", "2", time.Minute)
+	if ok, _ := b.L1.Exists("key"); ok {
+		t.Error("b kept its local copy after a's Set")
+	}
+	if v, _ := b.Get("key"); v != "2" {
+		t.Errorf("b.Get(key) == %q, want \"2\"", v)
+	}
+
+	a.SetWithTags("tagged", "1", time.Minute, "t")
+	b.Get("tagged")
+	a.InvalidateTags("t")
+	if ok, _ := b.L1.Exists("tagged"); ok {
+		t.Error("b kept its local copy after a's InvalidateTags")
+	}
+
+	b.Get("key")
+	a.Delete("key")
+	if _, err := b.Get("key"); err != ErrMiss {
+		t.Errorf("b.Get(key) after a's Delete error == %v, want ErrMiss", err)
+	}
+
+	// nil means messages were lost, everything local goes
+	b.Set("other", "1", time.Minute)
+	b.evict(nil)
+	if n := b.L1.Len(); n != 0 {
+		t.Errorf("b.L1 holds %d keys after evict(nil), want 0", n)
+	}
+}
+
+func TestRedisInvalidator(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	client := Connect(addr, os.Getenv("REDIS_PASSWORD"), 0)
+	defer client.Close()
+	channel := "caching_test:" + newToken()
+	a := NewRedisInvalidator(client, channel)
+	b := NewRedisInvalidator(client, channel)
+	defer a.Close()
+	defer b.Close()
+
+	received := make(chan []string, 10)
+	a.Listen(func(keys []string) { received <- keys })
+	b.Listen(func(keys []string) { received <- keys })
+	// Let both subscriptions settle
+	time.Sleep(100 * time.Millisecond)
+
+	if err := a.Publish("k1", "k2"); err != nil {
+		t.Fatalf("Publish error: %s", err)
+	}
+	select {
+	case keys := <-received:
+		if len(keys) != 2 || keys[0] != "k1" || keys[1] != "k2" {
+			t.Errorf("b received %q, want [k1 k2]", keys)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("b received nothing")
+	}
+	// a ignores its own message
+	select {
+	case keys := <-received:
+		t.Errorf("received %q twice", keys)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
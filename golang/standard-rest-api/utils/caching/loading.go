@@ -0,0 +1,211 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/standard-rest-api/logger"
+	"github.com/golang/standard-rest-api/utils/metrics"
+)
+
+var (
+	cacheLoads = metrics.NewCounter("cache_loads_total", "Number of loader calls after a cache miss or a stale hit.", "result")
+	cacheStale = metrics.NewCounter("cache_stale_hits_total", "Number of stale values served while they are refreshed.")
+)
+
+// LoaderFunc loads the value of a key missing from the cache and the tags
+// the value is stored with
+type LoaderFunc func(ctx context.Context) (value string, tags []string, err error)
+
+// LoadingCache is a read-through Cache. The values are fresh for the ttl
+// given to GetOrLoad, then served stale for Grace more while a single
+// goroutine reloads them. Concurrent misses of a key share one loader call.
+type LoadingCache struct {
+	Cache
+	// Grace is how long a value is served stale after its ttl
+	Grace time.Duration
+	// NotFound is the error a loader returns for a missing record, it is
+	// cached for NegativeTTL and returned by GetOrLoad meanwhile.
+	NotFound    error
+	NegativeTTL time.Duration
+	// LoadTimeout bounds a loader call, which outlives the requests
+	// waiting for it
+	LoadTimeout time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]*loadCall
+	// generation is bumped by every invalidation
+	generation atomic.Uint64
+}
+
+type loadCall struct {
+	done chan struct{}
+	val  string
+	err  error
+}
+
+func NewLoadingCache(c Cache, grace time.Duration, notFound error, negativeTTL time.Duration) *LoadingCache {
+	return &LoadingCache{
+		Cache:       c,
+		Grace:       grace,
+		NotFound:    notFound,
+		NegativeTTL: negativeTTL,
+		LoadTimeout: 10 * time.Second,
+		inflight:    make(map[string]*loadCall),
+	}
+}
+
+// loadEntry is stored as "v<fresh until>:<value>", or "n<fresh until>:" for
+// a negative entry, fresh until being in unix nanoseconds.
+type loadEntry struct {
+	val      string
+	notFound bool
+	fresh    time.Time
+}
+
+func (e *loadEntry) String() string {
+	kind := "v"
+	if e.notFound {
+		kind = "n"
+	}
+	return kind + strconv.FormatInt(e.fresh.UnixNano(), 10) + ":" + e.val
+}
+
+func parseLoadEntry(s string) (*loadEntry, bool) {
+	if len(s) < 2 || (s[0] != 'v' && s[0] != 'n') {
+		return nil, false
+	}
+	fresh, val, ok := strings.Cut(s[1:], ":")
+	if !ok {
+		return nil, false
+	}
+	nsec, err := strconv.ParseInt(fresh, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &loadEntry{val: val, notFound: s[0] == 'n', fresh: time.Unix(0, nsec)}, true
+}
+
+func (lc *LoadingCache) result(e *loadEntry) (string, error) {
+	if e.notFound {
+		return "", lc.NotFound
+	}
+	return e.val, nil
+}
+
+// GetOrLoad returns the cached value of key, loading it on a miss. A stale
+// value is returned right away and refreshed in the background.
+func (lc *LoadingCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (string, error) {
+	s, err := lc.Cache.Get(key)
+	if err != nil && err != ErrMiss {
+		// The cache is down, the loads below are still coalesced
+		logger.Warn("cache get %s error:%s", key, err)
+	}
+	if err == nil {
+		if e, ok := parseLoadEntry(s); ok {
+			if time.Now().After(e.fresh) {
+				cacheStale.Inc()
+				lc.refresh(ctx, key, ttl, loader)
+			}
+			return lc.result(e)
+		}
+	}
+	return lc.load(ctx, key, ttl, loader)
+}
+
+// load starts loader unless a call for key is in flight and waits for the
+// result until ctx is done. The call itself doesn't depend on ctx, a waiter
+// going away doesn't fail the others.
+func (lc *LoadingCache) load(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (string, error) {
+	lc.mu.Lock()
+	c, ok := lc.inflight[key]
+	if !ok {
+		c = lc.start(ctx, key, ttl, loader)
+	}
+	lc.mu.Unlock()
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// refresh reloads key in the background unless a call is already in flight
+func (lc *LoadingCache) refresh(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if _, busy := lc.inflight[key]; !busy {
+		lc.start(ctx, key, ttl, loader)
+	}
+}
+
+// start runs loader in its own goroutine with the values of ctx but not its
+// cancellation, bounded by LoadTimeout. The caller must hold mu.
+func (lc *LoadingCache) start(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) *loadCall {
+	c := &loadCall{done: make(chan struct{})}
+	lc.inflight[key] = c
+	go func() {
+		ctx := context.WithoutCancel(ctx)
+		if lc.LoadTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, lc.LoadTimeout)
+			defer cancel()
+		}
+		c.val, c.err = lc.call(ctx, key, ttl, loader)
+
+		lc.mu.Lock()
+		delete(lc.inflight, key)
+		lc.mu.Unlock()
+		close(c.done)
+	}()
+	return c
+}
+
+// Delete and InvalidateTags move to a new generation first, the loads
+// started before them don't store what they read.
+func (lc *LoadingCache) Delete(keys ...string) (int64, error) {
+	lc.generation.Add(1)
+	return lc.Cache.Delete(keys...)
+}
+
+func (lc *LoadingCache) InvalidateTags(tags ...string) ([]string, error) {
+	lc.generation.Add(1)
+	return lc.Cache.InvalidateTags(tags...)
+}
+
+func (lc *LoadingCache) call(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (string, error) {
+	generation := lc.generation.Load()
+	val, tags, err := loader(ctx)
+	e := &loadEntry{val: val}
+	expiration := ttl + lc.Grace
+	switch {
+	case err == nil:
+		cacheLoads.Inc("ok")
+	case lc.NotFound != nil && errors.Is(err, lc.NotFound):
+		cacheLoads.Inc("not_found")
+		if lc.NegativeTTL <= 0 {
+			return "", err
+		}
+		// A negative entry is never served stale, the record may show up
+		e.notFound, ttl, expiration = true, lc.NegativeTTL, lc.NegativeTTL
+	default:
+		cacheLoads.Inc("error")
+		return "", err
+	}
+	e.fresh = time.Now().Add(ttl)
+	if lc.generation.Load() != generation {
+		// Invalidated while loading, what was read may be the old version
+		cacheLoads.Inc("discarded")
+		return lc.result(e)
+	}
+	if err := lc.Cache.SetWithTags(key, e.String(), expiration, tags...); err != nil {
+		logger.Warn("cache set %s error:%s", key, err)
+	}
+	return lc.result(e)
+}
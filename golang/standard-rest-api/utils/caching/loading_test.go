@@ -0,0 +1,194 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errNotFound = errors.New("not found")
+
+func newTestLoadingCache() *LoadingCache {
+	return NewLoadingCache(NewMemory(0, 0), time.Minute, errNotFound, time.Minute)
+}
+
+// eventually waits for cond, the loading cache stores in the background
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met after 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLoadingCacheCoalescesMisses(t *testing.T) {
+	lc := newTestLoadingCache()
+	var calls int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (string, []string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := lc.GetOrLoad(context.Background(), "key", time.Minute, loader)
+			if err != nil {
+				t.Errorf("GetOrLoad error: %s", err)
+			}
+			results[i] = v
+		}(i)
+	}
+	// Let every goroutine join the call before it returns
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+	for i, v := range results {
+		if v != "value" {
+			t.Errorf("results[%d] == %q, want \"value\"", i, v)
+		}
+	}
+}
+
+func TestLoadingCacheWaiterCancellation(t *testing.T) {
+	lc := newTestLoadingCache()
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (string, []string, error) {
+		select {
+		case <-release:
+			return "value", nil, nil
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := lc.GetOrLoad(ctx, "key", time.Minute, loader)
+		leader <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	waiter := make(chan string, 1)
+	go func() {
+		v, _ := lc.GetOrLoad(context.Background(), "key", time.Minute, loader)
+		waiter <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// The request that started the load goes away, the other one still
+	// gets the value
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Errorf("leader error == %v, want context.Canceled", err)
+	}
+	close(release)
+	if v := <-waiter; v != "value" {
+		t.Errorf("waiter got %q, want \"value\"", v)
+	}
+}
+
+func TestLoadingCacheServesStale(t *testing.T) {
+	lc := newTestLoadingCache()
+	var calls int32
+	loader := func(ctx context.Context) (string, []string, error) {
+		n := atomic.AddInt32(&calls, 1)
+		return string(rune('0' + n)), nil, nil
+	}
+	ctx := context.Background()
+
+	if v, _ := lc.GetOrLoad(ctx, "key", 10*time.Millisecond, loader); v != "1" {
+		t.Fatalf("first GetOrLoad == %q, want \"1\"", v)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// Past the ttl but within the grace the old value comes back at once
+	if v, _ := lc.GetOrLoad(ctx, "key", 10*time.Millisecond, loader); v != "1" {
+		t.Errorf("stale GetOrLoad == %q, want \"1\"", v)
+	}
+	// Read the backing cache, another GetOrLoad could start a refresh
+	eventually(t, func() bool {
+		s, err := lc.Cache.Get("key")
+		if err != nil {
+			return false
+		}
+		e, ok := parseLoadEntry(s)
+		return ok && e.val == "2"
+	})
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+}
+
+func TestLoadingCacheNegative(t *testing.T) {
+	lc := newTestLoadingCache()
+	var calls int32
+	loader := func(ctx context.Context) (string, []string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "", nil, errNotFound
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := lc.GetOrLoad(ctx, "key", time.Minute, loader); err != errNotFound {
+			t.Errorf("GetOrLoad error == %v, want errNotFound", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+
+	// Other errors are not cached
+	failures := 0
+	failing := func(ctx context.Context) (string, []string, error) {
+		failures++
+		return "", nil, errors.New("down")
+	}
+	lc.GetOrLoad(ctx, "other", time.Minute, failing)
+	lc.GetOrLoad(ctx, "other", time.Minute, failing)
+	if failures != 2 {
+		t.Errorf("failing loader called %d times, want 2", failures)
+	}
+}
+
+func TestLoadingCacheInvalidationDuringLoad(t *testing.T) {
+	lc := newTestLoadingCache()
+	started, release := make(chan struct{}), make(chan struct{})
+	loader := func(ctx context.Context) (string, []string, error) {
+		close(started)
+		<-release
+		return "old", []string{"tag"}, nil
+	}
+
+	done := make(chan string, 1)
+	go func() {
+		v, _ := lc.GetOrLoad(context.Background(), "key", time.Minute, loader)
+		done <- v
+	}()
+	<-started
+	lc.InvalidateTags("tag")
+	close(release)
+
+	// The caller still gets what was read, but it isn't stored
+	if v := <-done; v != "old" {
+		t.Errorf("GetOrLoad == %q, want \"old\"", v)
+	}
+	if ok, _ := lc.Exists("key"); ok {
+		t.Error("the value read before the invalidation was stored")
+	}
+}
@@ -0,0 +1,189 @@
+package caching
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	mrand "math/rand"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	// ErrNotAcquired is returned by TryAcquire when another holder has the lock
+	ErrNotAcquired = errors.New("caching: lock not acquired")
+	// ErrNotHeld is returned when the lease expired, the lock may have been
+	// acquired by someone else since.
+	ErrNotHeld = errors.New("caching: lock not held")
+)
+
+// LockStore keeps the leases of a Locker. A lease is a key holding the
+// token of its holder, it is only released or extended with that token.
+type LockStore interface {
+	AcquireLock(key, token string, ttl time.Duration) (bool, error)
+	ReleaseLock(key, token string) (bool, error)
+	ExtendLock(key, token string, ttl time.Duration) (bool, error)
+}
+
+// Locker hands out lease based locks shared by every instance using the
+// same Store. A holder that doesn't release or extend its lease within the
+// ttl loses the lock, so the ttl must cover the work or be extended along.
+type Locker struct {
+	Store LockStore
+	// Prefix namespaces the lock keys
+	Prefix string
+	// MinBackoff and MaxBackoff bound the jittered wait between two
+	// attempts of Acquire, it doubles after every failed attempt.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func NewLocker(store LockStore) *Locker {
+	return &Locker{
+		Store:      store,
+		Prefix:     "lock:",
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 500 * time.Millisecond,
+	}
+}
+
+// Lock is a held lease
+type Lock struct {
+	Key   string
+	Token string
+	store LockStore
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// TryAcquire takes the lock for ttl, or fails with ErrNotAcquired
+func (l *Locker) TryAcquire(name string, ttl time.Duration) (*Lock, error) {
+	lock := &Lock{Key: l.Prefix + name, Token: newToken(), store: l.Store}
+	ok, err := l.Store.AcquireLock(lock.Key, lock.Token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	return lock, nil
+}
+
+// minBackoff keeps Acquire from spinning on the store when MinBackoff is 0
+const minBackoff = time.Millisecond
+
+// Acquire retries TryAcquire until it gets the lock or ctx is done
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	backoff, maxBackoff := l.MinBackoff, l.MaxBackoff
+	if backoff < minBackoff {
+		backoff = minBackoff
+	}
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+	for {
+		lock, err := l.TryAcquire(name, ttl)
+		if err != ErrNotAcquired {
+			return lock, err
+		}
+		// Jitter spreads the waiters so they don't retry in lockstep
+		wait := backoff/2 + time.Duration(mrand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Release gives the lock back, ErrNotHeld means the lease had expired
+func (lk *Lock) Release() error {
+	ok, err := lk.store.ReleaseLock(lk.Key, lk.Token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Extend resets the lease to ttl from now, ErrNotHeld means it had expired
+func (lk *Lock) Extend(ttl time.Duration) error {
+	ok, err := lk.store.ExtendLock(lk.Key, lk.Token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+var releaseLock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+var extendLock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func (r *Redis) AcquireLock(key, token string, ttl time.Duration) (bool, error) {
+	ok, err := r.Client.SetNX(key, token, ttl).Result()
+	return ok, r.failed("lock", err)
+}
+
+func (r *Redis) ReleaseLock(key, token string) (bool, error) {
+	n, err := releaseLock.Run(r.Client, []string{key}, token).Int64()
+	return n == 1, r.failed("unlock", err)
+}
+
+func (r *Redis) ExtendLock(key, token string, ttl time.Duration) (bool, error) {
+	n, err := extendLock.Run(r.Client, []string{key}, token, ttl.Milliseconds()).Int64()
+	return n == 1, r.failed("extend_lock", err)
+}
+
+// AcquireLock stores the lease like any other key, use a Memory without
+// MaxEntries for locks or the LRU may evict a held lease.
+func (m *Memory) AcquireLock(key, token string, ttl time.Duration) (bool, error) {
+	return m.SetNX(key, token, ttl)
+}
+
+func (m *Memory) ReleaseLock(key, token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.lookup(key)
+	if entry == nil || entry.value != token {
+		return false, nil
+	}
+	m.remove(m.items[key])
+	return true, nil
+}
+
+func (m *Memory) ExtendLock(key, token string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.lookup(key)
+	if entry == nil || entry.value != token {
+		return false, nil
+	}
+	entry.expiresAt = time.Now().Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,119 @@
+package caching
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// testLocks runs against every LockStore, name keeps the lock apart from
+// the other runs sharing the store.
+func testLocks(t *testing.T, store LockStore, name string) {
+	l := NewLocker(store)
+
+	lock, err := l.TryAcquire(name, time.Minute)
+	if err != nil {
+		t.Fatalf("TryAcquire error: %s", err)
+	}
+	if _, err := l.TryAcquire(name, time.Minute); err != ErrNotAcquired {
+		t.Errorf("second TryAcquire error == %v, want ErrNotAcquired", err)
+	}
+
+	// Only the holder's token releases or extends the lease
+	other := &Lock{Key: lock.Key, Token: newToken(), store: store}
+	if err := other.Extend(time.Minute); err != ErrNotHeld {
+		t.Errorf("Extend with another token error == %v, want ErrNotHeld", err)
+	}
+	if err := other.Release(); err != ErrNotHeld {
+		t.Errorf("Release with another token error == %v, want ErrNotHeld", err)
+	}
+	if err := lock.Extend(time.Minute); err != nil {
+		t.Errorf("Extend error: %s", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("Release error: %s", err)
+	}
+	if err := lock.Release(); err != ErrNotHeld {
+		t.Errorf("second Release error == %v, want ErrNotHeld", err)
+	}
+
+	// An expired lease is free for the next holder and lost for the old one
+	lock, err = l.TryAcquire(name, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("TryAcquire error: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	next, err := l.TryAcquire(name, time.Minute)
+	if err != nil {
+		t.Fatalf("TryAcquire after expiry error: %s", err)
+	}
+	if err := lock.Extend(time.Minute); err != ErrNotHeld {
+		t.Errorf("Extend of an expired lease error == %v, want ErrNotHeld", err)
+	}
+	if err := lock.Release(); err != ErrNotHeld {
+		t.Errorf("Release of an expired lease error == %v, want ErrNotHeld", err)
+	}
+	next.Release()
+}
+
+func TestMemoryLocks(t *testing.T) {
+	testLocks(t, NewMemory(0, 0), "test")
+}
+
+func TestRedisLocks(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	r := &Redis{Client: Connect(addr, os.Getenv("REDIS_PASSWORD"), 0)}
+	defer r.Close()
+	testLocks(t, r, "caching_test:"+newToken())
+}
+
+func TestLockerAcquireWaits(t *testing.T) {
+	l := NewLocker(NewMemory(0, 0))
+	lock, err := l.TryAcquire("test", time.Minute)
+	if err != nil {
+		t.Fatalf("TryAcquire error: %s", err)
+	}
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		lock.Release()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	next, err := l.Acquire(ctx, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire error: %s", err)
+	}
+	next.Release()
+}
+
+func TestLockerAcquireTimeout(t *testing.T) {
+	// A zero backoff is clamped rather than spinning on the store
+	l := &Locker{Store: NewMemory(0, 0), Prefix: "lock:"}
+	if _, err := l.TryAcquire("test", time.Minute); err != nil {
+		t.Fatalf("TryAcquire error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "test", time.Minute); err != context.DeadlineExceeded {
+		t.Errorf("Acquire error == %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestNamespacedLocks(t *testing.T) {
+	m := NewMemory(0, 0)
+	l := NewLocker(NewNamespaced(m, "app:v1:", 0))
+	lock, err := l.TryAcquire("test", time.Minute)
+	if err != nil {
+		t.Fatalf("TryAcquire error: %s", err)
+	}
+	defer lock.Release()
+	if ok, _ := m.Exists("app:v1:lock:test"); !ok {
+		t.Error("the lease isn't stored under the namespace")
+	}
+}
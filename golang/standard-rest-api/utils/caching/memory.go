@@ -0,0 +1,323 @@
+package caching
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/metrics"
+)
+
+var cacheEvictions = metrics.NewCounter("cache_evictions_total", "Number of keys evicted to stay under the size limit.", "backend")
+
+// ErrNotInteger is returned by Incr when the stored value isn't an integer
+var ErrNotInteger = errors.New("caching: value is not an integer")
+
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+	tags      []string
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// Memory is an in-process Cache bounded to MaxEntries keys, the least
+// recently used key is evicted first. Expired keys are dropped when they are
+// read and by a janitor running in the background.
+type Memory struct {
+	// MaxEntries is the size limit, zero means unbounded
+	MaxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	// tags holds the keys of every tag
+	tags map[string]map[string]struct{}
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewMemory returns a Memory cache, the janitor removes the expired keys every
+// cleanupInterval and is disabled when the interval isn't positive.
+func NewMemory(maxEntries int, cleanupInterval time.Duration) *Memory {
+	m := &Memory{
+		MaxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+		stop:       make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go m.janitor(cleanupInterval)
+	}
+	return m
+}
+
+func (m *Memory) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.DeleteExpired(now)
+		}
+	}
+}
+
+// DeleteExpired removes the keys that expired at now
+func (m *Memory) DeleteExpired(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for e := m.ll.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*memoryEntry).expired(now) {
+			m.remove(e)
+		}
+		e = next
+	}
+}
+
+// Flush removes every key
+func (m *Memory) Flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ll.Init()
+	m.items = make(map[string]*list.Element)
+	m.tags = make(map[string]map[string]struct{})
+}
+
+// Close stops the janitor
+func (m *Memory) Close() error {
+	m.once.Do(func() { close(m.stop) })
+	return nil
+}
+
+// Len returns the number of keys, including the expired ones not collected yet
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+// lookup returns the live entry of key and marks it as recently used. The
+// caller must hold mu.
+func (m *Memory) lookup(key string) *memoryEntry {
+	e, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	entry := e.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		m.remove(e)
+		return nil
+	}
+	m.ll.MoveToFront(e)
+	return entry
+}
+
+// store sets key with tags, replacing the tags it had, and evicts the oldest
+// keys over MaxEntries. The caller must hold mu.
+func (m *Memory) store(key, value string, expiration time.Duration, tags ...string) {
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = time.Now().Add(expiration)
+	}
+	entry := &memoryEntry{key: key}
+	if e, ok := m.items[key]; ok {
+		entry = e.Value.(*memoryEntry)
+		m.untag(entry)
+		m.ll.MoveToFront(e)
+	} else {
+		m.items[key] = m.ll.PushFront(entry)
+	}
+	entry.value, entry.expiresAt, entry.tags = value, expiresAt, tags
+	for _, tag := range tags {
+		keys, ok := m.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			m.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for m.MaxEntries > 0 && m.ll.Len() > m.MaxEntries {
+		m.remove(m.ll.Back())
+		cacheEvictions.Inc("memory")
+	}
+}
+
+func (m *Memory) untag(entry *memoryEntry) {
+	for _, tag := range entry.tags {
+		delete(m.tags[tag], entry.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+	entry.tags = nil
+}
+
+func (m *Memory) remove(e *list.Element) {
+	entry := e.Value.(*memoryEntry)
+	m.untag(entry)
+	m.ll.Remove(e)
+	delete(m.items, entry.key)
+}
+
+func (m *Memory) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.lookup(key)
+	if entry == nil {
+		cacheMisses.Inc("memory")
+		return "", ErrMiss
+	}
+	cacheHits.Inc("memory")
+	return entry.value, nil
+}
+
+func (m *Memory) Set(key, value string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store(key, value, expiration)
+	return nil
+}
+
+func (m *Memory) SetNX(key, value string, expiration time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lookup(key) != nil {
+		return false, nil
+	}
+	m.store(key, value, expiration)
+	return true, nil
+}
+
+func (m *Memory) Delete(keys ...string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if m.lookup(key) != nil {
+			m.remove(m.items[key])
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lookup(key) != nil, nil
+}
+
+func (m *Memory) TTL(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.lookup(key)
+	if entry == nil {
+		return 0, ErrMiss
+	}
+	if entry.expiresAt.IsZero() {
+		return NoExpiration, nil
+	}
+	return time.Until(entry.expiresAt), nil
+}
+
+// Expire behaves like the redis command, a non positive expiration deletes
+// the key.
+func (m *Memory) Expire(key string, expiration time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.lookup(key)
+	if entry == nil {
+		return false, nil
+	}
+	if expiration <= 0 {
+		m.remove(m.items[key])
+		return true, nil
+	}
+	entry.expiresAt = time.Now().Add(expiration)
+	return true, nil
+}
+
+func (m *Memory) Incr(key string) (int64, error) {
+	return m.IncrBy(key, 1)
+}
+
+// IncrBy keeps the expiration of an existing key, a missing key starts at
+// zero and doesn't expire.
+func (m *Memory) IncrBy(key string, value int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.lookup(key)
+	if entry == nil {
+		m.store(key, strconv.FormatInt(value, 10), 0)
+		return value, nil
+	}
+	n, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	n += value
+	entry.value = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func (m *Memory) MGet(keys ...string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		entry := m.lookup(key)
+		if entry == nil {
+			cacheMisses.Inc("memory")
+			continue
+		}
+		cacheHits.Inc("memory")
+		values[key] = entry.value
+	}
+	return values, nil
+}
+
+func (m *Memory) MSet(values map[string]string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range values {
+		m.store(k, v, expiration)
+	}
+	return nil
+}
+
+func (m *Memory) SetWithTags(key, value string, expiration time.Duration, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store(key, value, expiration, tags...)
+	return nil
+}
+
+func (m *Memory) InvalidateTags(tags ...string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted []string
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			e := m.items[key]
+			if !e.Value.(*memoryEntry).expired(time.Now()) {
+				deleted = append(deleted, key)
+			}
+			m.remove(e)
+		}
+	}
+	return deleted, nil
+}
+
+func (m *Memory) Ping() error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package caching
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	m := NewMemory(2, 0)
+	m.Set("a", "1", NoExpiration)
+	m.Set("b", "2", NoExpiration)
+	// Reading a makes b the least recently used key
+	if _, err := m.Get("a"); err != nil {
+		t.Fatalf("Get(a) error: %s", err)
+	}
+	m.Set("c", "3", NoExpiration)
+
+	if _, err := m.Get("b"); err != ErrMiss {
+		t.Errorf("Get(b) error == %v, want ErrMiss", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := m.Get(key); err != nil {
+			t.Errorf("Get(%s) error: %s", key, err)
+		}
+	}
+	if n := m.Len(); n != 2 {
+		t.Errorf("Len() == %d, want 2", n)
+	}
+}
+
+func TestMemoryOverwriteDoesNotEvict(t *testing.T) {
+	m := NewMemory(2, 0)
+	m.Set("a", "1", NoExpiration)
+	m.Set("b", "2", NoExpiration)
+	m.Set("a", "3", NoExpiration)
+
+	if v, err := m.Get("a"); err != nil || v != "3" {
+		t.Errorf("Get(a) == %q, %v, want \"3\", nil", v, err)
+	}
+	if v, err := m.Get("b"); err != nil || v != "2" {
+		t.Errorf("Get(b) == %q, %v, want \"2\", nil", v, err)
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	m := NewMemory(0, 0)
+	m.Set("short", "1", 20*time.Millisecond)
+	m.Set("forever", "2", NoExpiration)
+
+	if ttl, err := m.TTL("short"); err != nil || ttl <= 0 || ttl > 20*time.Millisecond {
+		t.Errorf("TTL(short) == %s, %v, want (0, 20ms]", ttl, err)
+	}
+	if ttl, err := m.TTL("forever"); err != nil || ttl != NoExpiration {
+		t.Errorf("TTL(forever) == %s, %v, want NoExpiration", ttl, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := m.Get("short"); err != ErrMiss {
+		t.Errorf("Get(short) error == %v, want ErrMiss", err)
+	}
+	if ok, _ := m.Exists("short"); ok {
+		t.Error("Exists(short) == true after expiry")
+	}
+	if _, err := m.Get("forever"); err != nil {
+		t.Errorf("Get(forever) error: %s", err)
+	}
+}
+
+func TestMemoryDeleteExpired(t *testing.T) {
+	m := NewMemory(0, 0)
+	m.Set("a", "1", time.Minute)
+	m.Set("b", "2", time.Hour)
+	m.Set("c", "3", NoExpiration)
+
+	m.DeleteExpired(time.Now().Add(2 * time.Minute))
+	if n := m.Len(); n != 2 {
+		t.Errorf("Len() == %d, want 2", n)
+	}
+	if _, err := m.Get("a"); err != ErrMiss {
+		t.Errorf("Get(a) error == %v, want ErrMiss", err)
+	}
+}
+
+func TestMemoryJanitor(t *testing.T) {
+	m := NewMemory(0, 10*time.Millisecond)
+	defer m.Close()
+	m.Set("a", "1", 5*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for m.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the janitor did not remove the expired key")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMemoryExpire(t *testing.T) {
+	m := NewMemory(0, 0)
+	if ok, _ := m.Expire("missing", time.Minute); ok {
+		t.Error("Expire(missing) == true")
+	}
+	m.Set("a", "1", NoExpiration)
+	if ok, _ := m.Expire("a", 10*time.Millisecond); !ok {
+		t.Error("Expire(a) == false")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := m.Get("a"); err != ErrMiss {
+		t.Errorf("Get(a) error == %v, want ErrMiss", err)
+	}
+}
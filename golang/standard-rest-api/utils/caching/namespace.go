@@ -0,0 +1,241 @@
+package caching
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang/standard-rest-api/logger"
+	"github.com/golang/standard-rest-api/utils/metrics"
+)
+
+var (
+	familyLookups = metrics.NewCounter("cache_family_lookups_total",
+		"Number of cache lookups by key family and result.", "family", "result")
+	familyErrors = metrics.NewCounter("cache_family_errors_total",
+		"Number of failed cache operations by key family.", "family", "op")
+	familyDuration = metrics.NewHistogram("cache_operation_duration_seconds",
+		"Cache operation latencies in seconds by key family.",
+		[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}, "family", "op")
+)
+
+// Namespaced prefixes every key and tag with Prefix, e.g. "rest:v1:", so
+// environments and releases sharing a redis don't read each other's
+// entries. It records the cache_family_* metrics per key family, the part
+// of the key before the first ":", and warns about the operations slower
+// than SlowThreshold when it is set.
+type Namespaced struct {
+	Cache         Cache
+	Prefix        string
+	SlowThreshold time.Duration
+}
+
+func NewNamespaced(c Cache, prefix string, slowThreshold time.Duration) *Namespaced {
+	return &Namespaced{
+		Cache:         c,
+		Prefix:        prefix,
+		SlowThreshold: slowThreshold,
+	}
+}
+
+// Family returns the family of key, "other" for a key without one so the
+// metrics labels stay bounded.
+func Family(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return "other"
+}
+
+// observe records an operation on family that started at start. Only the
+// family is logged, the keys may hold secrets like session tokens.
+func (n *Namespaced) observe(op, family string, start time.Time, err error) {
+	d := time.Since(start)
+	familyDuration.Observe(d.Seconds(), family, op)
+	if err != nil && err != ErrMiss {
+		familyErrors.Inc(family, op)
+	}
+	if n.SlowThreshold > 0 && d >= n.SlowThreshold {
+		logger.Warn("slow cache %s on %s keys took %s (error %v)", op, family, d, err)
+	}
+}
+
+func (n *Namespaced) key(key string) string {
+	return n.Prefix + key
+}
+
+func (n *Namespaced) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = n.Prefix + key
+	}
+	return prefixed
+}
+
+// familyOf names the family of a multi key operation after its first key
+func familyOf(keys []string) string {
+	if len(keys) == 0 {
+		return "other"
+	}
+	return Family(keys[0])
+}
+
+func (n *Namespaced) Get(key string) (string, error) {
+	start := time.Now()
+	val, err := n.Cache.Get(n.key(key))
+	family := Family(key)
+	n.observe("get", family, start, err)
+	switch err {
+	case nil:
+		familyLookups.Inc(family, "hit")
+	case ErrMiss:
+		familyLookups.Inc(family, "miss")
+	}
+	return val, err
+}
+
+func (n *Namespaced) Set(key, value string, expiration time.Duration) error {
+	start := time.Now()
+	err := n.Cache.Set(n.key(key), value, expiration)
+	n.observe("set", Family(key), start, err)
+	return err
+}
+
+func (n *Namespaced) SetNX(key, value string, expiration time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := n.Cache.SetNX(n.key(key), value, expiration)
+	n.observe("setnx", Family(key), start, err)
+	return ok, err
+}
+
+func (n *Namespaced) Delete(keys ...string) (int64, error) {
+	start := time.Now()
+	deleted, err := n.Cache.Delete(n.keys(keys)...)
+	n.observe("delete", familyOf(keys), start, err)
+	return deleted, err
+}
+
+func (n *Namespaced) Exists(key string) (bool, error) {
+	start := time.Now()
+	ok, err := n.Cache.Exists(n.key(key))
+	n.observe("exists", Family(key), start, err)
+	return ok, err
+}
+
+func (n *Namespaced) TTL(key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := n.Cache.TTL(n.key(key))
+	n.observe("ttl", Family(key), start, err)
+	return ttl, err
+}
+
+func (n *Namespaced) Expire(key string, expiration time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := n.Cache.Expire(n.key(key), expiration)
+	n.observe("expire", Family(key), start, err)
+	return ok, err
+}
+
+func (n *Namespaced) Incr(key string) (int64, error) {
+	return n.IncrBy(key, 1)
+}
+
+func (n *Namespaced) IncrBy(key string, value int64) (int64, error) {
+	start := time.Now()
+	v, err := n.Cache.IncrBy(n.key(key), value)
+	n.observe("incr", Family(key), start, err)
+	return v, err
+}
+
+func (n *Namespaced) MGet(keys ...string) (map[string]string, error) {
+	start := time.Now()
+	found, err := n.Cache.MGet(n.keys(keys)...)
+	n.observe("mget", familyOf(keys), start, err)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(found))
+	for _, key := range keys {
+		family := Family(key)
+		if v, ok := found[n.key(key)]; ok {
+			values[key] = v
+			familyLookups.Inc(family, "hit")
+		} else {
+			familyLookups.Inc(family, "miss")
+		}
+	}
+	return values, nil
+}
+
+func (n *Namespaced) MSet(values map[string]string, expiration time.Duration) error {
+	prefixed := make(map[string]string, len(values))
+	family := "other"
+	for k, v := range values {
+		prefixed[n.key(k)] = v
+		family = Family(k)
+	}
+	start := time.Now()
+	err := n.Cache.MSet(prefixed, expiration)
+	n.observe("mset", family, start, err)
+	return err
+}
+
+func (n *Namespaced) SetWithTags(key, value string, expiration time.Duration, tags ...string) error {
+	start := time.Now()
+	err := n.Cache.SetWithTags(n.key(key), value, expiration, n.keys(tags)...)
+	n.observe("set", Family(key), start, err)
+	return err
+}
+
+// InvalidateTags is recorded under the family of its first tag
+func (n *Namespaced) InvalidateTags(tags ...string) ([]string, error) {
+	start := time.Now()
+	deleted, err := n.Cache.InvalidateTags(n.keys(tags)...)
+	n.observe("invalidate_tags", familyOf(tags), start, err)
+	for i, key := range deleted {
+		deleted[i] = strings.TrimPrefix(key, n.Prefix)
+	}
+	return deleted, err
+}
+
+func (n *Namespaced) Ping() error {
+	return n.Cache.Ping()
+}
+
+// errNoLocks is returned by the lock methods when Cache isn't a LockStore
+var errNoLocks = errors.New("caching: the cache doesn't support locks")
+
+// AcquireLock, ReleaseLock and ExtendLock namespace the lock keys as well,
+// so environments sharing a redis don't contend on the same locks.
+func (n *Namespaced) AcquireLock(key, token string, ttl time.Duration) (bool, error) {
+	store, ok := n.Cache.(LockStore)
+	if !ok {
+		return false, errNoLocks
+	}
+	start := time.Now()
+	acquired, err := store.AcquireLock(n.key(key), token, ttl)
+	n.observe("lock", Family(key), start, err)
+	return acquired, err
+}
+
+func (n *Namespaced) ReleaseLock(key, token string) (bool, error) {
+	store, ok := n.Cache.(LockStore)
+	if !ok {
+		return false, errNoLocks
+	}
+	start := time.Now()
+	released, err := store.ReleaseLock(n.key(key), token)
+	n.observe("unlock", Family(key), start, err)
+	return released, err
+}
+
+func (n *Namespaced) ExtendLock(key, token string, ttl time.Duration) (bool, error) {
+	store, ok := n.Cache.(LockStore)
+	if !ok {
+		return false, errNoLocks
+	}
+	start := time.Now()
+	extended, err := store.ExtendLock(n.key(key), token, ttl)
+	n.observe("extend_lock", Family(key), start, err)
+	return extended, err
+}
@@ -0,0 +1,123 @@
+package caching
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestNamespacedPrefixesKeys(t *testing.T) {
+	m := NewMemory(0, 0)
+	n := NewNamespaced(m, "rest:v1:", 0)
+
+	n.Set("token:abc", "1", time.Minute)
+	if v, err := m.Get("rest:v1:token:abc"); err != nil || v != "1" {
+		t.Errorf("backend Get of the prefixed key == %q, %v", v, err)
+	}
+	if _, err := m.Get("token:abc"); err != ErrMiss {
+		t.Errorf("the bare key was written, error == %v", err)
+	}
+	if v, err := n.Get("token:abc"); err != nil || v != "1" {
+		t.Errorf("Get(token:abc) == %q, %v, want \"1\"", v, err)
+	}
+
+	n.MSet(map[string]string{"a": "1", "b": "2"}, time.Minute)
+	values, err := n.MGet("a", "b", "missing")
+	if err != nil || len(values) != 2 || values["a"] != "1" || values["b"] != "2" {
+		t.Errorf("MGet == %v, %v, want the unprefixed keys a and b", values, err)
+	}
+	if ok, _ := m.Exists("rest:v1:a"); !ok {
+		t.Error("MSet didn't prefix the keys")
+	}
+
+	if v, err := n.Incr("counter"); err != nil || v != 1 {
+		t.Errorf("Incr == %d, %v, want 1", v, err)
+	}
+	if v, _ := m.Get("rest:v1:counter"); v != "1" {
+		t.Errorf("backend counter == %q, want \"1\"", v)
+	}
+	if ok, _ := n.SetNX("a", "3", time.Minute); ok {
+		t.Error("SetNX overwrote an existing key")
+	}
+}
+
+func TestNamespacedTTL(t *testing.T) {
+	m := NewMemory(0, 0)
+	n := NewNamespaced(m, "rest:v1:", 0)
+
+	n.Set("forever", "1", NoExpiration)
+	if ttl, err := n.TTL("forever"); err != nil || ttl != NoExpiration {
+		t.Errorf("TTL(forever) == %s, %v, want NoExpiration", ttl, err)
+	}
+	n.Set("minute", "1", time.Minute)
+	if ttl, err := n.TTL("minute"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL(minute) == %s, %v, want (0, 1m]", ttl, err)
+	}
+	if ok, _ := n.Expire("forever", time.Minute); !ok {
+		t.Error("Expire(forever) == false")
+	}
+	if ttl, _ := m.TTL("rest:v1:forever"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("backend TTL after Expire == %s, want (0, 1m]", ttl)
+	}
+	if _, err := n.TTL("missing"); err != ErrMiss {
+		t.Errorf("TTL(missing) error == %v, want ErrMiss", err)
+	}
+}
+
+func TestNamespacedTagsAndDelete(t *testing.T) {
+	m := NewMemory(0, 0)
+	n := NewNamespaced(m, "rest:v1:", 0)
+	// Another release sharing the backend uses the same tag names
+	other := NewNamespaced(m, "rest:v2:", 0)
+
+	n.SetWithTags("job:1", "a", time.Minute, "job:1", "feed")
+	n.SetWithTags("feed:1:10", "b", time.Minute, "feed")
+	other.SetWithTags("feed:1:10", "c", time.Minute, "feed")
+
+	deleted, err := n.InvalidateTags("feed")
+	sort.Strings(deleted)
+	if err != nil || len(deleted) != 2 || deleted[0] != "feed:1:10" || deleted[1] != "job:1" {
+		t.Errorf("InvalidateTags(feed) == %q, %v, want the unprefixed keys", deleted, err)
+	}
+	if v, _ := other.Get("feed:1:10"); v != "c" {
+		t.Errorf("the other namespace lost its entry, Get == %q", v)
+	}
+
+	n.Set("a", "1", time.Minute)
+	n.Set("b", "1", time.Minute)
+	if deleted, err := n.Delete("a", "b", "missing"); err != nil || deleted != 2 {
+		t.Errorf("Delete == %d, %v, want 2", deleted, err)
+	}
+	if m.Len() != 1 {
+		t.Errorf("backend holds %d keys, want only the other namespace's", m.Len())
+	}
+}
+
+// plainCache hides the LockStore methods of the cache it wraps
+type plainCache struct {
+	Cache
+}
+
+func TestNamespacedWithoutLocks(t *testing.T) {
+	n := NewNamespaced(plainCache{NewMemory(0, 0)}, "rest:v1:", 0)
+	if _, err := NewLocker(n).TryAcquire("migrate", time.Minute); err != errNoLocks {
+		t.Errorf("TryAcquire error == %v, want errNoLocks", err)
+	}
+}
+
+func TestFamily(t *testing.T) {
+	cases := []struct {
+		key, want string
+	}{
+		{"token:abc", "token"},
+		{"job:1", "job"},
+		{"feed:1:10", "feed"},
+		{"plain", "other"},
+		{":leading", "other"},
+	}
+	for _, c := range cases {
+		if got := Family(c.key); got != c.want {
+			t.Errorf("Family(%q) == %q, want %q", c.key, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package caching
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// tagKey is the redis set holding the keys of a tag
+func tagKey(tag string) string {
+	return "tag:" + tag
+}
+
+// setWithTags sets KEYS[1] to ARGV[1] for ARGV[2] milliseconds, zero
+// meaning no expiration, and adds it to the tag sets KEYS[2..]. A tag set
+// lives as long as its longest lived key.
+var setWithTags = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	local existed = redis.call('EXISTS', KEYS[i]) == 1
+	redis.call('SADD', KEYS[i], KEYS[1])
+	if ttl == 0 then
+		redis.call('PERSIST', KEYS[i])
+	else
+		local current = redis.call('PTTL', KEYS[i])
+		if not existed or (current >= 0 and current < ttl) then
+			redis.call('PEXPIRE', KEYS[i], ttl)
+		end
+	end
+end
+return 1
+`)
+
+// invalidateTags deletes the members of the tag sets KEYS and the sets
+// themselves, it returns the keys that existed. The member keys aren't
+// declared in KEYS, which is fine for a single redis but not for a cluster.
+var invalidateTags = redis.NewScript(`
+local deleted = {}
+for i = 1, #KEYS do
+	for _, key in ipairs(redis.call('SMEMBERS', KEYS[i])) do
+		if redis.call('DEL', key) == 1 then
+			table.insert(deleted, key)
+		end
+	end
+	redis.call('DEL', KEYS[i])
+end
+return deleted
+`)
+
+func (r *Redis) SetWithTags(key, value string, expiration time.Duration, tags ...string) error {
+	keys := make([]string, 0, len(tags)+1)
+	keys = append(keys, key)
+	for _, tag := range tags {
+		keys = append(keys, tagKey(tag))
+	}
+	ttl := int64(0)
+	if expiration > 0 {
+		ttl = int64(expiration / time.Millisecond)
+		if ttl == 0 {
+			ttl = 1
+		}
+	}
+	return r.failed("set_tags", setWithTags.Run(r.Client, keys, value, ttl).Err())
+}
+
+func (r *Redis) InvalidateTags(tags ...string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = tagKey(tag)
+	}
+	res, err := invalidateTags.Run(r.Client, keys).Result()
+	if err != nil {
+		return nil, r.failed("invalidate_tags", err)
+	}
+	vals, _ := res.([]interface{})
+	deleted := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if key, ok := v.(string); ok {
+			deleted = append(deleted, key)
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,93 @@
+package caching
+
+import (
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testTags runs against every Cache implementing tags, prefix keeps the
+// keys apart from whatever else lives in the cache.
+func testTags(t *testing.T, c Cache, prefix string) {
+	a, b, d := prefix+"a", prefix+"b", prefix+"d"
+	c.SetWithTags(a, "1", time.Minute, prefix+"job:1", prefix+"feed")
+	c.SetWithTags(b, "2", time.Minute, prefix+"job:2", prefix+"feed")
+	c.SetWithTags(d, "3", time.Minute, prefix+"job:3")
+
+	deleted, err := c.InvalidateTags(prefix + "job:1")
+	if err != nil {
+		t.Fatalf("InvalidateTags error: %s", err)
+	}
+	if len(deleted) != 1 || deleted[0] != a {
+		t.Errorf("InvalidateTags(job:1) == %q, want [%s]", deleted, a)
+	}
+	if _, err := c.Get(b); err != nil {
+		t.Errorf("Get(b) error: %s", err)
+	}
+
+	// The invalidated key isn't reported twice and b goes with the feed
+	deleted, _ = c.InvalidateTags(prefix+"feed", prefix+"job:1")
+	if len(deleted) != 1 || deleted[0] != b {
+		t.Errorf("InvalidateTags(feed, job:1) == %q, want [%s]", deleted, b)
+	}
+	if _, err := c.Get(d); err != nil {
+		t.Errorf("Get(d) error: %s", err)
+	}
+
+	// Setting the key again replaces its value, a missing tag is a no-op
+	c.SetWithTags(d, "4", time.Minute, prefix+"job:3", prefix+"feed")
+	if v, _ := c.Get(d); v != "4" {
+		t.Errorf("Get(d) == %q, want \"4\"", v)
+	}
+	if deleted, _ := c.InvalidateTags(prefix + "missing"); len(deleted) != 0 {
+		t.Errorf("InvalidateTags(missing) == %q, want none", deleted)
+	}
+	deleted, _ = c.InvalidateTags(prefix+"job:3", prefix+"feed")
+	sort.Strings(deleted)
+	if len(deleted) != 1 || deleted[0] != d {
+		t.Errorf("InvalidateTags(job:3, feed) == %q, want [%s]", deleted, d)
+	}
+}
+
+func TestMemoryTags(t *testing.T) {
+	m := NewMemory(0, 0)
+	testTags(t, m, "")
+
+	// An evicted key leaves its tags
+	m = NewMemory(1, 0)
+	m.SetWithTags("a", "1", NoExpiration, "t")
+	m.Set("b", "2", NoExpiration)
+	if deleted, _ := m.InvalidateTags("t"); len(deleted) != 0 {
+		t.Errorf("InvalidateTags(t) == %q after eviction, want none", deleted)
+	}
+}
+
+// TestRedisTags runs the Lua scripts against the redis at REDIS_ADDR
+func TestRedisTags(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	r := &Redis{Client: Connect(addr, os.Getenv("REDIS_PASSWORD"), 0)}
+	defer r.Close()
+	if err := r.Ping(); err != nil {
+		t.Fatalf("Ping error: %s", err)
+	}
+	prefix := "caching_test:" + newToken() + ":"
+	testTags(t, r, prefix)
+
+	// A tag set lives as long as its longest lived key
+	r.SetWithTags(prefix+"short", "1", time.Second, prefix+"t")
+	r.SetWithTags(prefix+"long", "2", time.Minute, prefix+"t")
+	r.SetWithTags(prefix+"shorter", "3", time.Second, prefix+"t")
+	ttl, err := r.Client.PTTL(tagKey(prefix + "t")).Result()
+	if err != nil || ttl <= 30*time.Second {
+		t.Errorf("tag set TTL == %s, %v, want about 1m", ttl, err)
+	}
+	r.SetWithTags(prefix+"forever", "4", NoExpiration, prefix+"t")
+	if ttl, _ := r.Client.PTTL(tagKey(prefix + "t")).Result(); ttl >= 0 {
+		t.Errorf("tag set TTL == %s, want none", ttl)
+	}
+	r.InvalidateTags(prefix + "t")
+}
@@ -0,0 +1,124 @@
+package caching
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/standard-rest-api/logger"
+)
+
+// payloadVersion is the layout of the header written by Serializer
+const payloadVersion = 1
+
+const flagCompressed = 1 << 0
+
+// ErrStaleVersion is returned by Decode for an entry written with another
+// Serializer.Version, GetAs reports it as a miss.
+var ErrStaleVersion = errors.New("caching: entry has another version")
+
+// Serializer turns values into cache entries. An entry starts with a header
+// holding the payload layout, the codec, the flags and the Version, so
+// entries written by an older release are told apart instead of being
+// decoded into the wrong shape.
+type Serializer struct {
+	Codec Codec
+	// Version is bumped when the cached type changes incompatibly
+	Version uint32
+	// CompressAbove gzips the encoded values larger than this many bytes,
+	// zero disables the compression
+	CompressAbove int
+}
+
+var DefaultSerializer = &Serializer{Codec: JSON, CompressAbove: 1024}
+
+func (s *Serializer) Encode(v interface{}) (string, error) {
+	var body bytes.Buffer
+	if err := s.Codec.Encode(&body, v); err != nil {
+		return "", err
+	}
+	var flags byte
+	if s.CompressAbove > 0 && body.Len() > s.CompressAbove {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		if _, err := body.WriteTo(zw); err != nil {
+			return "", err
+		}
+		if err := zw.Close(); err != nil {
+			return "", err
+		}
+		body, flags = compressed, flags|flagCompressed
+	}
+
+	header := make([]byte, 3+binary.MaxVarintLen32)
+	header[0], header[1], header[2] = payloadVersion, s.Codec.ID(), flags
+	n := 3 + binary.PutUvarint(header[3:], uint64(s.Version))
+	return string(header[:n]) + body.String(), nil
+}
+
+func (s *Serializer) Decode(data string, v interface{}) error {
+	if len(data) < 4 || data[0] != payloadVersion {
+		return errors.New("caching: unknown payload layout")
+	}
+	codec, ok := codecs[data[1]]
+	if !ok {
+		return fmt.Errorf("caching: unknown codec %d", data[1])
+	}
+	flags := data[2]
+	r := bytes.NewReader([]byte(data[3:]))
+	version, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if uint32(version) != s.Version {
+		return ErrStaleVersion
+	}
+	var body io.Reader = r
+	if flags&flagCompressed != 0 {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		body = zr
+	}
+	return codec.Decode(body, v)
+}
+
+// GetAs returns the value of key decoded by s, DefaultSerializer when s is
+// nil. An entry that can't be decoded, e.g. one of another version, is
+// reported as ErrMiss so the caller reloads it.
+func GetAs[T any](c Cache, s *Serializer, key string) (T, error) {
+	var v T
+	if s == nil {
+		s = DefaultSerializer
+	}
+	data, err := c.Get(key)
+	if err != nil {
+		return v, err
+	}
+	if err := s.Decode(data, &v); err != nil {
+		if err != ErrStaleVersion {
+			logger.Warn("cache decode %s error:%s", key, err)
+		}
+		var zero T
+		return zero, ErrMiss
+	}
+	return v, nil
+}
+
+// SetAs stores v encoded by s, DefaultSerializer when s is nil
+func SetAs[T any](c Cache, s *Serializer, key string, v T, expiration time.Duration) error {
+	if s == nil {
+		s = DefaultSerializer
+	}
+	data, err := s.Encode(v)
+	if err != nil {
+		return err
+	}
+	return c.Set(key, data, expiration)
+}
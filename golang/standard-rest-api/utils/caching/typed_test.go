@@ -0,0 +1,117 @@
+package caching
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type cachedJob struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Tags      []string  `json:"tags"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func TestSerializerRoundTrip(t *testing.T) {
+	job := cachedJob{
+		ID:        1,
+		Title:     "Gopher",
+		Tags:      []string{"go", "remote"},
+		UpdatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	big := job
+	big.Title = strings.Repeat("Gopher ", 500)
+
+	for _, codec := range []Codec{JSON, Gob, MessagePack} {
+		s := &Serializer{Codec: codec, Version: 3, CompressAbove: 1024}
+		for _, v := range []cachedJob{job, big} {
+			data, err := s.Encode(v)
+			if err != nil {
+				t.Fatalf("codec %d: Encode error: %s", codec.ID(), err)
+			}
+			compressed := data[2]&flagCompressed != 0
+			if want := len(v.Title) > 1024; compressed != want {
+				t.Errorf("codec %d: compressed == %v for a %d bytes title, want %v", codec.ID(), compressed, len(v.Title), want)
+			}
+			if data[1] != codec.ID() {
+				t.Errorf("codec %d: header names codec %d", codec.ID(), data[1])
+			}
+			var got cachedJob
+			if err := s.Decode(data, &got); err != nil {
+				t.Fatalf("codec %d: Decode error: %s", codec.ID(), err)
+			}
+			if got.ID != v.ID || got.Title != v.Title || len(got.Tags) != 2 || !got.UpdatedAt.Equal(v.UpdatedAt) {
+				t.Errorf("codec %d: Decode == %+v, want %+v", codec.ID(), got, v)
+			}
+		}
+	}
+}
+
+func TestSerializerReadsTheCodecOfTheEntry(t *testing.T) {
+	// A release switching codecs still reads the entries of the previous one
+	data, _ := (&Serializer{Codec: Gob, Version: 1}).Encode(cachedJob{ID: 7})
+	var got cachedJob
+	if err := (&Serializer{Codec: JSON, Version: 1}).Decode(data, &got); err != nil || got.ID != 7 {
+		t.Errorf("Decode == %+v, %v, want ID 7", got, err)
+	}
+}
+
+func TestSerializerStaleVersion(t *testing.T) {
+	data, _ := (&Serializer{Codec: JSON, Version: 1}).Encode(cachedJob{ID: 1})
+	var got cachedJob
+	if err := (&Serializer{Codec: JSON, Version: 2}).Decode(data, &got); err != ErrStaleVersion {
+		t.Errorf("Decode of another version error == %v, want ErrStaleVersion", err)
+	}
+}
+
+func TestSerializerCorruptPayloads(t *testing.T) {
+	s := &Serializer{Codec: JSON, Version: 1, CompressAbove: 16}
+	valid, _ := s.Encode(cachedJob{Title: strings.Repeat("x", 100)})
+	cases := map[string]string{
+		"empty":            "",
+		"short":            "\x01\x01",
+		"unknown layout":   "\x09" + valid[1:],
+		"unknown codec":    valid[:1] + "\x7f" + valid[2:],
+		"truncated gzip":   valid[:len(valid)/2],
+		"not gzip":         valid[:4] + "not gzip at all",
+		"plain json cache": `{"id":1}`,
+	}
+	for name, data := range cases {
+		var got cachedJob
+		if err := s.Decode(data, &got); err == nil {
+			t.Errorf("Decode of a %s payload succeeded", name)
+		}
+	}
+}
+
+func TestGetAsSetAs(t *testing.T) {
+	m := NewMemory(0, 0)
+	v1 := &Serializer{Codec: MessagePack, Version: 1}
+
+	if err := SetAs(m, v1, "job:1", cachedJob{ID: 1, Title: "Gopher"}, time.Minute); err != nil {
+		t.Fatalf("SetAs error: %s", err)
+	}
+	got, err := GetAs[cachedJob](m, v1, "job:1")
+	if err != nil || got.ID != 1 || got.Title != "Gopher" {
+		t.Errorf("GetAs == %+v, %v", got, err)
+	}
+
+	// Entries of another version or that don't decode are misses
+	if _, err := GetAs[cachedJob](m, &Serializer{Codec: MessagePack, Version: 2}, "job:1"); err != ErrMiss {
+		t.Errorf("GetAs of another version error == %v, want ErrMiss", err)
+	}
+	m.Set("job:2", "garbage", time.Minute)
+	if got, err := GetAs[cachedJob](m, v1, "job:2"); err != ErrMiss || got.ID != 0 {
+		t.Errorf("GetAs of a corrupt entry == %+v, %v, want the zero value and ErrMiss", got, err)
+	}
+	if _, err := GetAs[cachedJob](m, v1, "job:3"); err != ErrMiss {
+		t.Errorf("GetAs of a missing key error == %v, want ErrMiss", err)
+	}
+
+	// A nil Serializer is DefaultSerializer
+	SetAs(m, nil, "n", 42, time.Minute)
+	if n, err := GetAs[int](m, DefaultSerializer, "n"); err != nil || n != 42 {
+		t.Errorf("GetAs with DefaultSerializer == %d, %v, want 42", n, err)
+	}
+}
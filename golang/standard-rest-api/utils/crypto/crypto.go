@@ -3,6 +3,7 @@ package crypto
 import (
 	"io"
 	"crypto/rand"
+	"crypto/subtle"
 	"log"
 	"encoding/hex"
 	"encoding/base64"
@@ -17,22 +18,52 @@ func GenerateSalt() string {
 	if err != nil {
 		log.Fatal(err)
 	}
-	salt := make([]byte, 32)
-	hex.Decode(salt, saltBytes)
-	return string(salt)
+	return hex.EncodeToString(saltBytes)
 }
 
 //HashPassword hashes a string
 func HashPassword(password, salt string) string {
+	return hex.EncodeToString(scryptKey(password, salt))
+}
+
+func scryptKey(password, salt string) []byte {
 	hashPasswordBytes, err := scrypt.Key([]byte(password), []byte(salt), 16384, 8, 1, 32)
 	if err != nil {
 		log.Fatal("Unable to hash password")
 	}
+	return hashPasswordBytes
+}
+
+// legacyHashPassword is how the hashes were encoded before they were hex
+// encoded: the raw key was hex decoded, which stops at the first byte
+// that isn't a hex digit and leaves NUL bytes behind.
+func legacyHashPassword(password, salt string) string {
 	hashPassword := make([]byte, 64)
-	hex.Decode(hashPassword, hashPasswordBytes)
+	hex.Decode(hashPassword, scryptKey(password, salt))
 	return string(hashPassword)
 }
 
+// isLegacyHash reports a hash stored by legacyHashPassword, a current hash
+// is 64 hex digits.
+func isLegacyHash(hash string) bool {
+	if len(hash) != 64 {
+		return true
+	}
+	_, err := hex.DecodeString(hash)
+	return err != nil
+}
+
+// VerifyPassword checks password against a stored hash and salt. rehash
+// reports a legacy hash that matched, the caller should store a new hash
+// with HashPassword.
+func VerifyPassword(password, salt, hash string) (ok, rehash bool) {
+	if isLegacyHash(hash) {
+		ok = subtle.ConstantTimeCompare([]byte(legacyHashPassword(password, salt)), []byte(hash)) == 1
+		return ok, ok
+	}
+	return subtle.ConstantTimeCompare([]byte(HashPassword(password, salt)), []byte(hash)) == 1, false
+}
+
 func GenerateToken() (string, error) {
 	b := make([]byte, 64)
 	_, err := rand.Read(b)
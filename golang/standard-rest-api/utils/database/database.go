@@ -1,14 +1,200 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/golang/standard-rest-api/config"
 	_ "github.com/lib/pq"
 )
 
-func Connect(user, password, dbname, host, port string) (*sql.DB, error) {
-	connStr := fmt.Sprintf("user=%s, password=%s, dbname=%s, host=%s, port=%s",
-		user, password, dbname, host, port)
-	return sql.Open("postgres", connStr)
+// DSN holds the settings of a Postgres connection string
+type DSN struct {
+	User            string
+	Password        string
+	DBName          string
+	Host            string
+	Port            int
+	SSLMode         string
+	ConnectTimeout  time.Duration
+	ApplicationName string
+}
+
+// NewDSN reads the [database] section, the PG* environment variables
+// are used for the keys that aren't set.
+func NewDSN(conf config.Configer) *DSN {
+	port, err := strconv.Atoi(os.Getenv("PGPORT"))
+	if err != nil {
+		port = 5432
+	}
+	return &DSN{
+		User:            conf.DefaultString("database::user", os.Getenv("PGUSER")),
+		Password:        conf.DefaultString("database::password", os.Getenv("PGPASS")),
+		DBName:          conf.DefaultString("database::dbname", os.Getenv("PGDB")),
+		Host:            conf.DefaultString("database::host", os.Getenv("PGHOST")),
+		Port:            conf.DefaultInt("database::port", port),
+		SSLMode:         conf.DefaultString("database::sslmode", "require"),
+		ConnectTimeout:  conf.DefaultDuration("database::connect_timeout", 5*time.Second),
+		ApplicationName: conf.DefaultString("database::application_name", "standard-rest-api"),
+	}
+}
+
+// Replica copies the DSN for a replica at addr, host or host:port
+func (d *DSN) Replica(addr string) *DSN {
+	r := *d
+	r.Host = addr
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		r.Host = host
+		r.Port, _ = strconv.Atoi(port)
+	}
+	return &r
+}
+
+// String builds a key=value connection string, every value is quoted so
+// passwords may contain spaces, quotes and backslashes.
+func (d *DSN) String() string {
+	var parts []string
+	add := func(key, value string) {
+		if value == "" {
+			return
+		}
+		parts = append(parts, key+"="+quote(value))
+	}
+	add("user", d.User)
+	add("password", d.Password)
+	add("dbname", d.DBName)
+	add("host", d.Host)
+	if d.Port > 0 {
+		add("port", strconv.Itoa(d.Port))
+	}
+	add("sslmode", d.SSLMode)
+	if d.ConnectTimeout > 0 {
+		// libpq only takes whole seconds, round up so 500ms isn't "no timeout"
+		add("connect_timeout", strconv.Itoa(int((d.ConnectTimeout+time.Second-1)/time.Second)))
+	}
+	add("application_name", d.ApplicationName)
+	return strings.Join(parts, " ")
+}
+
+// Redacted is the connection string with the password hidden, for logs
+func (d *DSN) Redacted() string {
+	c := *d
+	if c.Password != "" {
+		c.Password = "xxxxx"
+	}
+	return c.String()
+}
+
+var quoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func quote(v string) string {
+	return "'" + quoter.Replace(v) + "'"
+}
+
+// Options tunes the connection pool and the startup retries of Connect
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectAttempts is how many times the first ping is tried,
+	// waiting from InitialBackoff up to MaxBackoff in between.
+	ConnectAttempts int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+}
+
+// NewOptions reads the pool and retry settings of the [database] section
+func NewOptions(conf config.Configer) *Options {
+	return &Options{
+		MaxOpenConns:    conf.DefaultInt("database::max_open_conns", 25),
+		MaxIdleConns:    conf.DefaultInt("database::max_idle_conns", 25),
+		ConnMaxLifetime: conf.DefaultDuration("database::conn_max_lifetime", 30*time.Minute),
+		ConnMaxIdleTime: conf.DefaultDuration("database::conn_max_idle_time", 5*time.Minute),
+		ConnectAttempts: conf.DefaultInt("database::connect_attempts", 5),
+		InitialBackoff:  conf.DefaultDuration("database::connect_initial_backoff", time.Second),
+		MaxBackoff:      conf.DefaultDuration("database::connect_max_backoff", 30*time.Second),
+	}
+}
+
+// Connect opens the pool and pings Postgres until it answers, so a database
+// that starts slower than the API doesn't fail the first requests.
+func Connect(ctx context.Context, dsn *DSN, opts *Options) (*sql.DB, error) {
+	db, err := Open(dsn, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := pingWithRetry(ctx, db, opts); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database: connect to %s:%d/%s failed after %d attempts: %s",
+			dsn.Host, dsn.Port, dsn.DBName, attempts(opts), err)
+	}
+	return db, nil
+}
+
+// Open configures the pool without dialing, like sql.Open
+func Open(dsn *DSN, opts *Options) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	return db, nil
+}
+
+func attempts(opts *Options) int {
+	if opts.ConnectAttempts < 1 {
+		return 1
+	}
+	return opts.ConnectAttempts
+}
+
+// retryWait jitters backoff to [backoff/2, 3*backoff/2) so a fleet of
+// instances doesn't reconnect in lockstep, and caps it at max when set
+func retryWait(backoff, max time.Duration) time.Duration {
+	wait := time.Duration(rand.Int63n(int64(backoff))) + backoff/2
+	if max > 0 && wait > max {
+		return max
+	}
+	return wait
+}
+
+func pingWithRetry(ctx context.Context, db *sql.DB, opts *Options) error {
+	backoff := opts.InitialBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			return nil
+		}
+		if attempt >= attempts(opts) {
+			return err
+		}
+
+		wait := retryWait(backoff, opts.MaxBackoff)
+		log.Printf("Ping database attempt %d failed:%s, retrying in %s", attempt, err, wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if opts.MaxBackoff > 0 && backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
 }
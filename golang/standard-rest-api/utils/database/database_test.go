@@ -0,0 +1,99 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDSNString(t *testing.T) {
+	cases := []struct {
+		dsn  DSN
+		want string
+	}{
+		{
+			DSN{User: "api", Password: "secret", DBName: "rest", Host: "db", Port: 5432, SSLMode: "require"},
+			`user='api' password='secret' dbname='rest' host='db' port='5432' sslmode='require'`,
+		},
+		// Spaces, quotes and backslashes stay inside the value
+		{
+			DSN{User: "api", Password: `pa ss'w\rd`},
+			`user='api' password='pa ss\'w\\rd'`,
+		},
+		{
+			DSN{Password: `' host=evil`},
+			`password='\' host=evil'`,
+		},
+		// Empty values are left out, the timeout is rounded up to seconds
+		{
+			DSN{Host: "db", ConnectTimeout: 500 * time.Millisecond, ApplicationName: "standard rest"},
+			`host='db' connect_timeout='1' application_name='standard rest'`,
+		},
+		{
+			DSN{Host: "db", ConnectTimeout: 5 * time.Second},
+			`host='db' connect_timeout='5'`,
+		},
+		{DSN{}, ``},
+	}
+	for _, c := range cases {
+		if got := c.dsn.String(); got != c.want {
+			t.Errorf("String() == %s, want %s", got, c.want)
+		}
+	}
+}
+
+func TestDSNRedacted(t *testing.T) {
+	cases := []DSN{
+		{User: "api", Password: "secret", Host: "db"},
+		{User: "api", Password: `s3cr'et\`, Host: "db"},
+		{User: "api", Password: "secret host=db", Host: "db"},
+	}
+	for _, d := range cases {
+		got := d.Redacted()
+		if strings.Contains(got, "secret") || strings.Contains(got, "s3cr") {
+			t.Errorf("Redacted() == %s leaks the password", got)
+		}
+		if !strings.Contains(got, "password='xxxxx'") || !strings.Contains(got, "user='api'") {
+			t.Errorf("Redacted() == %s, want the other settings and a masked password", got)
+		}
+		if d.Password == "" || !strings.Contains(d.String(), "password=") {
+			t.Error("Redacted() changed the DSN")
+		}
+	}
+	if got := (&DSN{User: "api"}).Redacted(); got != `user='api'` {
+		t.Errorf("Redacted() without a password == %s, want user='api'", got)
+	}
+}
+
+func TestDSNReplica(t *testing.T) {
+	d := &DSN{User: "api", Password: "secret", Host: "primary", Port: 5432}
+	cases := []struct {
+		addr string
+		host string
+		port int
+	}{
+		{"replica-1", "replica-1", 5432},
+		{"replica-2:5433", "replica-2", 5433},
+	}
+	for _, c := range cases {
+		r := d.Replica(c.addr)
+		if r.Host != c.host || r.Port != c.port || r.Password != "secret" {
+			t.Errorf("Replica(%q) == %+v", c.addr, r)
+		}
+	}
+	if d.Host != "primary" {
+		t.Error("Replica changed the primary DSN")
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if wait := retryWait(time.Second, 0); wait < 500*time.Millisecond || wait >= 1500*time.Millisecond {
+			t.Fatalf("retryWait(1s, 0) == %s, want [500ms, 1.5s)", wait)
+		}
+		// The jitter never goes past the maximum
+		if wait := retryWait(30*time.Second, 30*time.Second); wait < 15*time.Second || wait > 30*time.Second {
+			t.Fatalf("retryWait(30s, 30s) == %s, want [15s, 30s]", wait)
+		}
+	}
+}
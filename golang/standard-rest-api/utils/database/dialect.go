@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect adapts the queries of the repositories, written for Postgres,
+// to the database they run on.
+type Dialect interface {
+	Name() string
+	// Rebind rewrites the $1, $2... placeholders of query
+	Rebind(query string) string
+	// InsertReturningID runs an "insert ... returning id" query and returns the id
+	InsertReturningID(ctx context.Context, db DBTX, query string, args ...interface{}) (int, error)
+	// Violation reports which kind of constraint err violated and its name
+	Violation(err error) (Violation, string)
+}
+
+type Violation int
+
+const (
+	NoViolation Violation = iota
+	UniqueViolation
+	ForeignKeyViolation
+)
+
+var (
+	Postgres Dialect = postgresDialect{}
+	SQLite   Dialect = sqliteDialect{}
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) Rebind(query string) string {
+	return query
+}
+
+func (postgresDialect) InsertReturningID(ctx context.Context, db DBTX, query string, args ...interface{}) (int, error) {
+	var id int
+	err := db.QueryRowContext(ctx, query, args...).Scan(&id)
+	return id, err
+}
+
+func (postgresDialect) Violation(err error) (Violation, string) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return NoViolation, ""
+	}
+	switch pqErr.Code {
+	case "23505":
+		return UniqueViolation, pqErr.Constraint
+	case "23503":
+		return ForeignKeyViolation, pqErr.Constraint
+	}
+	return NoViolation, ""
+}
+
+var (
+	placeholder = regexp.MustCompile(`\$(\d+)`)
+	returningID = regexp.MustCompile(`(?is)\s+returning\s+id\s*$`)
+)
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+// Rebind turns $1 into ?1, SQLite binds numbered parameters the same way
+func (sqliteDialect) Rebind(query string) string {
+	return placeholder.ReplaceAllString(query, "?$1")
+}
+
+// InsertReturningID drops the returning clause and reads the rowid, it
+// doesn't rely on the RETURNING support of newer SQLite versions.
+func (d sqliteDialect) InsertReturningID(ctx context.Context, db DBTX, query string, args ...interface{}) (int, error) {
+	query = returningID.ReplaceAllString(strings.TrimSpace(query), "")
+	res, err := db.ExecContext(ctx, d.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// Violation takes the constraint from the message, SQLite doesn't report
+// its name, e.g. "UNIQUE constraint failed: users.email".
+func (sqliteDialect) Violation(err error) (Violation, string) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return NoViolation, ""
+	}
+	constraint := sqliteErr.Error()
+	if i := strings.Index(constraint, ": "); i >= 0 {
+		constraint = constraint[i+2:]
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return UniqueViolation, constraint
+	case sqlite3.ErrConstraintForeignKey:
+		return ForeignKeyViolation, constraint
+	}
+	return NoViolation, ""
+}
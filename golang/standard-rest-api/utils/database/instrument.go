@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/standard-rest-api/logger"
+	"github.com/golang/standard-rest-api/utils/metrics"
+	"github.com/golang/standard-rest-api/utils/tracing"
+)
+
+// QueryEvent describes one finished query
+type QueryEvent struct {
+	Query    string
+	Args     []interface{}
+	Start    time.Time
+	Duration time.Duration
+	// RowsAffected is -1 for queries returning rows
+	RowsAffected int64
+	Err          error
+}
+
+// Statement is the lowercased first keyword of the query, e.g. "select"
+func (e *QueryEvent) Statement() string {
+	fields := strings.Fields(e.Query)
+	if len(fields) == 0 {
+		return "other"
+	}
+	switch s := strings.ToLower(fields[0]); s {
+	case "select", "insert", "update", "delete", "with":
+		return s
+	}
+	return "other"
+}
+
+// QueryObserver is notified after every query of an instrumented DBTX.
+// The rows of QueryContext may still be read after the notification, use
+// Query to have a query reported once its rows are closed.
+type QueryObserver interface {
+	ObserveQuery(ctx context.Context, e *QueryEvent)
+}
+
+// QueryTracer is a QueryObserver that is also told when a query starts,
+// e.g. to open a span. The context it returns runs the query and is the
+// one passed to its ObserveQuery.
+type QueryTracer interface {
+	QueryObserver
+	StartQuery(ctx context.Context, e *QueryEvent) context.Context
+}
+
+type QueryObserverFunc func(ctx context.Context, e *QueryEvent)
+
+func (f QueryObserverFunc) ObserveQuery(ctx context.Context, e *QueryEvent) {
+	f(ctx, e)
+}
+
+// Instrument wraps db so every query is reported to observers
+func Instrument(db DBTX, observers ...QueryObserver) DBTX {
+	if len(observers) == 0 {
+		return db
+	}
+	return &instrumentedDB{db: db, observers: observers}
+}
+
+// Observers returns the observers db was instrumented with, so a
+// transaction started from it can be instrumented the same way.
+func Observers(db DBTX) []QueryObserver {
+	if i, ok := db.(*instrumentedDB); ok {
+		return i.observers
+	}
+	return nil
+}
+
+type instrumentedDB struct {
+	db        DBTX
+	observers []QueryObserver
+}
+
+// start returns the event of query and the context of the tracers to run
+// it with
+func (i *instrumentedDB) start(ctx context.Context, query string, args []interface{}) (context.Context, *QueryEvent) {
+	e := &QueryEvent{
+		Query:        query,
+		Args:         args,
+		Start:        time.Now(),
+		RowsAffected: -1,
+	}
+	for _, o := range i.observers {
+		if t, ok := o.(QueryTracer); ok {
+			ctx = t.StartQuery(ctx, e)
+		}
+	}
+	return ctx, e
+}
+
+func (i *instrumentedDB) finish(ctx context.Context, e *QueryEvent, err error) {
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+	for _, o := range i.observers {
+		o.ObserveQuery(ctx, e)
+	}
+}
+
+func (i *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, e := i.start(ctx, query, args)
+	res, err := i.db.ExecContext(ctx, query, args...)
+	if err == nil {
+		if n, rerr := res.RowsAffected(); rerr == nil {
+			e.RowsAffected = n
+		}
+	}
+	i.finish(ctx, e, err)
+	return res, err
+}
+
+func (i *instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, e := i.start(ctx, query, args)
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	i.finish(ctx, e, err)
+	return rows, err
+}
+
+// QueryRowContext reports the error of the query itself, sql.ErrNoRows
+// only shows up on Scan and isn't a failure anyway.
+func (i *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, e := i.start(ctx, query, args)
+	row := i.db.QueryRowContext(ctx, query, args...)
+	i.finish(ctx, e, row.Err())
+	return row
+}
+
+// Rows are the rows of Query
+type Rows struct {
+	*sql.Rows
+	done func(err error)
+}
+
+// Close closes the rows and reports the query with the error that ended
+// the iteration, if any.
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	if done := r.done; done != nil {
+		r.done = nil
+		done(r.Rows.Err())
+	}
+	return err
+}
+
+// Query runs a query returning rows. On an instrumented db the query is
+// reported when the rows are closed, unlike with QueryContext the time
+// spent reading them counts.
+func Query(ctx context.Context, db DBTX, query string, args ...interface{}) (*Rows, error) {
+	i, ok := db.(*instrumentedDB)
+	if !ok {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &Rows{Rows: rows}, nil
+	}
+	ctx, e := i.start(ctx, query, args)
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		i.finish(ctx, e, err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, done: func(err error) { i.finish(ctx, e, err) }}, nil
+}
+
+var (
+	queryDuration = metrics.NewHistogram("db_query_duration_seconds",
+		"Database query latencies in seconds.", nil, "statement", "status")
+	queryRowsAffected = metrics.NewCounter("db_rows_affected_total",
+		"Number of rows changed by insert, update and delete queries.", "statement")
+)
+
+// MetricsObserver feeds the db_query_* metrics
+var MetricsObserver QueryObserver = QueryObserverFunc(func(ctx context.Context, e *QueryEvent) {
+	status := "ok"
+	if e.Err != nil {
+		status = "error"
+	}
+	statement := e.Statement()
+	queryDuration.Observe(e.Duration.Seconds(), statement, status)
+	if e.RowsAffected > 0 {
+		queryRowsAffected.Add(float64(e.RowsAffected), statement)
+	}
+})
+
+// TracingObserver records a span for every query, child of the span of
+// the request running it.
+var TracingObserver QueryObserver = queryTracer{}
+
+type queryTracer struct{}
+
+type querySpanKey struct{}
+
+func (queryTracer) StartQuery(ctx context.Context, e *QueryEvent) context.Context {
+	ctx, span := tracing.Start(ctx, "db."+e.Statement())
+	if span == nil {
+		return ctx
+	}
+	span.SetAttribute("db.statement", compact(e.Query))
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+func (queryTracer) ObserveQuery(ctx context.Context, e *QueryEvent) {
+	span, _ := ctx.Value(querySpanKey{}).(*tracing.Span)
+	if e.RowsAffected >= 0 {
+		span.SetAttribute("db.rows_affected", strconv.FormatInt(e.RowsAffected, 10))
+	}
+	span.End(e.Err)
+}
+
+// SlowQueryLogger warns about the queries slower than threshold, the
+// arguments are redacted since they hold emails and password hashes.
+func SlowQueryLogger(threshold time.Duration) QueryObserver {
+	return QueryObserverFunc(func(ctx context.Context, e *QueryEvent) {
+		if e.Duration < threshold {
+			return
+		}
+		logger.Warn("slow query took %s (rows affected %d, error %v): %s args=%s",
+			e.Duration, e.RowsAffected, e.Err, compact(e.Query), RedactArgs(e.Args))
+	})
+}
+
+// RedactArgs keeps the type of every argument and the value of numbers,
+// booleans and nulls only.
+func RedactArgs(args []interface{}) string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			redacted[i] = "NULL"
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
+			redacted[i] = fmt.Sprint(v)
+		case string:
+			redacted[i] = fmt.Sprintf("<string len=%d>", len(v))
+		case []byte:
+			redacted[i] = fmt.Sprintf("<bytes len=%d>", len(v))
+		default:
+			redacted[i] = fmt.Sprintf("<%T>", v)
+		}
+	}
+	return "[" + strings.Join(redacted, ", ") + "]"
+}
+
+// compact puts a multi-line query on a single log line
+func compact(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
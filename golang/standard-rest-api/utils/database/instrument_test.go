@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/standard-rest-api/utils/tracing"
+)
+
+// eventRecorder is a QueryTracer keeping the events it is notified of
+type eventRecorder struct {
+	started []*QueryEvent
+	events  []*QueryEvent
+	// ctxs are the contexts passed to ObserveQuery
+	ctxs []context.Context
+}
+
+type traceKey struct{}
+
+func (r *eventRecorder) StartQuery(ctx context.Context, e *QueryEvent) context.Context {
+	r.started = append(r.started, e)
+	return context.WithValue(ctx, traceKey{}, e)
+}
+
+func (r *eventRecorder) ObserveQuery(ctx context.Context, e *QueryEvent) {
+	r.events = append(r.events, e)
+	r.ctxs = append(r.ctxs, ctx)
+}
+
+func instrumentedSQLite(t *testing.T, observers ...QueryObserver) DBTX {
+	t.Helper()
+	db, err := ConnectSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`create table t (id integer primary key)`); err != nil {
+		t.Fatal(err)
+	}
+	return Instrument(db, observers...)
+}
+
+func TestQueryReportedOnClose(t *testing.T) {
+	r := &eventRecorder{}
+	db := instrumentedSQLite(t, r)
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, `insert into t (id) values (1), (2), (3)`); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := Query(ctx, db, `select id from t order by id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for rows.Next() {
+		n++
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(r.events) != 1 {
+		t.Fatalf("%d events before Close, want only the insert", len(r.events))
+	}
+	rows.Close()
+	rows.Close()
+
+	if n != 3 || len(r.events) != 2 {
+		t.Fatalf("read %d rows and saw %d events, want 3 rows and one event per query", n, len(r.events))
+	}
+	e := r.events[1]
+	if e.Duration < 30*time.Millisecond || e.Err != nil || e.RowsAffected != -1 {
+		t.Errorf("select event == %+v, want the time spent reading the rows", e)
+	}
+	if insert := r.events[0]; insert.RowsAffected != 3 {
+		t.Errorf("insert RowsAffected == %d, want 3", insert.RowsAffected)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	r := &eventRecorder{}
+	db := instrumentedSQLite(t, r)
+	if _, err := Query(context.Background(), db, `select id from missing`); err == nil {
+		t.Fatal("Query of a missing table succeeded")
+	}
+	if len(r.events) != 1 || r.events[0].Err == nil {
+		t.Errorf("events == %+v, want the failed query", r.events)
+	}
+
+	// Rows of a DBTX that isn't instrumented still work
+	plain := db.(*instrumentedDB).db
+	rows, err := Query(context.Background(), plain, `select id from t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryTracer(t *testing.T) {
+	r := &eventRecorder{}
+	var seen []context.Context
+	plain := QueryObserverFunc(func(ctx context.Context, e *QueryEvent) {
+		seen = append(seen, ctx)
+	})
+	db := instrumentedSQLite(t, r, plain)
+	ctx := context.Background()
+
+	db.ExecContext(ctx, `insert into t (id) values (1)`)
+	db.QueryRowContext(ctx, `select id from t`).Scan(new(int))
+	rows, _ := Query(ctx, db, `select id from t`)
+	rows.Close()
+	if rows, err := db.QueryContext(ctx, `select id from t`); err == nil {
+		rows.Close()
+	}
+
+	if len(r.started) != 4 || len(r.events) != 4 || len(seen) != 4 {
+		t.Fatalf("%d started, %d observed by the tracer and %d by the plain observer, want 4",
+			len(r.started), len(r.events), len(seen))
+	}
+	for i, e := range r.events {
+		if r.started[i] != e {
+			t.Errorf("query %d: the tracer started and observed different events", i)
+		}
+		// Every observer gets the context returned by StartQuery
+		if r.ctxs[i].Value(traceKey{}) != e || seen[i].Value(traceKey{}) != e {
+			t.Errorf("query %d: ObserveQuery didn't get the context of StartQuery", i)
+		}
+	}
+}
+
+func TestTracingObserver(t *testing.T) {
+	var spans []*tracing.Span
+	tracing.SetExporter(tracing.ExporterFunc(func(s *tracing.Span) {
+		spans = append(spans, s)
+	}))
+	defer tracing.SetExporter(nil)
+	db := instrumentedSQLite(t, TracingObserver)
+
+	ctx, request := tracing.Start(context.Background(), "http /job")
+	db.ExecContext(ctx, `insert into t (id) values (1)`)
+	_, err := db.ExecContext(ctx, `insert into t (id) values (1)`)
+	request.End(nil)
+
+	if len(spans) != 3 || spans[2] != request {
+		t.Fatalf("exported %d spans, want the two queries then the request", len(spans))
+	}
+	for _, s := range spans[:2] {
+		if s.Name != "db.insert" || s.ParentID != request.ID || s.TraceID != request.TraceID {
+			t.Errorf("query span == %+v, want a db.insert child of the request", s)
+		}
+		if s.Attributes["db.statement"] != "insert into t (id) values (1)" {
+			t.Errorf("db.statement == %q", s.Attributes["db.statement"])
+		}
+	}
+	if spans[0].Attributes["db.rows_affected"] != "1" || spans[0].Err != nil {
+		t.Errorf("first insert span == %+v, want 1 row affected", spans[0])
+	}
+	if spans[1].Err != err || err == nil {
+		t.Errorf("second insert span error == %v, want %v", spans[1].Err, err)
+	}
+}
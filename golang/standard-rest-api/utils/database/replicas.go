@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReadRouter picks the database a read-only query runs on
+type ReadRouter interface {
+	Reader(ctx context.Context) DBTX
+}
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so reads go to the primary, use it for
+// read-your-writes right after a mutation.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsePrimary reports whether ctx was marked with WithPrimary
+func UsePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	reader  DBTX
+	healthy int32
+}
+
+// Cluster sends reads round robin to the healthy replicas and falls back
+// to the primary when none is healthy.
+type Cluster struct {
+	Primary *sql.DB
+	// Observers are notified of the queries run through Reader, they are
+	// given to NewCluster as the readers are instrumented once
+	Observers []QueryObserver
+	primary   DBTX
+	replicas  []*replica
+	next      uint32
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewCluster(primary *sql.DB, observers ...QueryObserver) *Cluster {
+	return &Cluster{
+		Primary:   primary,
+		Observers: observers,
+		primary:   Instrument(primary, observers...),
+		stop:      make(chan struct{}),
+	}
+}
+
+// AddReplica registers a replica, it is considered healthy until a
+// health check fails.
+func (c *Cluster) AddReplica(name string, db *sql.DB) {
+	c.replicas = append(c.replicas, &replica{
+		name:    name,
+		db:      db,
+		reader:  Instrument(db, c.Observers...),
+		healthy: 1,
+	})
+}
+
+func (c *Cluster) Reader(ctx context.Context) DBTX {
+	if UsePrimary(ctx) || len(c.replicas) == 0 {
+		return c.primary
+	}
+	start := atomic.AddUint32(&c.next, 1)
+	for i := 0; i < len(c.replicas); i++ {
+		r := c.replicas[(int(start)+i)%len(c.replicas)]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r.reader
+		}
+	}
+	return c.primary
+}
+
+// StartHealthChecks pings every replica each interval until Close
+func (c *Cluster) StartHealthChecks(interval, timeout time.Duration) {
+	if len(c.replicas) == 0 {
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.checkReplicas(timeout)
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *Cluster) checkReplicas(timeout time.Duration) {
+	for _, r := range c.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.db.PingContext(ctx)
+		cancel()
+
+		var healthy int32
+		if err == nil {
+			healthy = 1
+		}
+		if old := atomic.SwapInt32(&r.healthy, healthy); old != healthy {
+			if err != nil {
+				log.Printf("Replica %s is unhealthy, reading from the primary instead:%s", r.name, err)
+			} else {
+				log.Printf("Replica %s is healthy again", r.name)
+			}
+		}
+	}
+}
+
+// Close stops the health checks and closes the replicas, then the primary
+func (c *Cluster) Close() error {
+	close(c.stop)
+	c.wg.Wait()
+	for _, r := range c.replicas {
+		if err := r.db.Close(); err != nil {
+			log.Printf("Close replica %s error:%s", r.name, err)
+		}
+	}
+	return c.Primary.Close()
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClusterReader(t *testing.T) {
+	dir := t.TempDir()
+	primary, err := ConnectSQLite(filepath.Join(dir, "primary.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, err := ConnectSQLite(filepath.Join(dir, "replica.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queries int32
+	observer := QueryObserverFunc(func(ctx context.Context, e *QueryEvent) {
+		atomic.AddInt32(&queries, 1)
+	})
+	c := NewCluster(primary, observer)
+	c.AddReplica("replica", replica)
+	defer c.Close()
+	ctx := context.Background()
+
+	// The readers are instrumented once, not on every call
+	r := c.Reader(ctx)
+	if c.Reader(ctx) != r {
+		t.Error("Reader returned another DBTX for the same replica")
+	}
+	if r != c.replicas[0].reader {
+		t.Error("Reader didn't pick the healthy replica")
+	}
+	p := c.Reader(WithPrimary(ctx))
+	if p != c.Reader(WithPrimary(ctx)) || p == r {
+		t.Error("Reader(WithPrimary) didn't return the instrumented primary")
+	}
+
+	atomic.StoreInt32(&c.replicas[0].healthy, 0)
+	if c.Reader(ctx) != p {
+		t.Error("Reader didn't fall back to the primary")
+	}
+
+	if _, err := r.ExecContext(ctx, "select 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ExecContext(ctx, "select 1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&queries); n != 2 {
+		t.Errorf("observer saw %d queries, want 2", n)
+	}
+}
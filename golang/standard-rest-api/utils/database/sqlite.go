@@ -0,0 +1,19 @@
+package database
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ConnectSQLite opens the SQLite database file at path, it is created when
+// missing. The driver needs cgo.
+func ConnectSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, serialize instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	return db, db.Ping()
+}
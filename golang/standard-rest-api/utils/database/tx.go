@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx so repositories can run
+// their queries inside or outside of a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// MaxTxRetries is how many times WithTx reruns a transaction that failed
+// on a serialization failure or a deadlock.
+const MaxTxRetries = 3
+
+// WithTx runs fn in a transaction, it commits when fn returns nil and rolls
+// back when fn returns an error or panics. A transaction aborted by a
+// serialization failure is retried from the start, so fn must not have
+// side effects outside of tx.
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return WithTxOptions(ctx, db, nil, fn)
+}
+
+// WithTxOptions is WithTx with an isolation level or a read only transaction
+func WithTxOptions(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 0; attempt <= MaxTxRetries; attempt++ {
+		if attempt > 0 {
+			// Back off 10ms, 20ms, 40ms with jitter before the rerun
+			backoff := time.Duration(10<<uint(attempt-1)) * time.Millisecond
+			backoff += time.Duration(rand.Int63n(int64(backoff)))
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err = runTx(ctx, db, opts, fn)
+		if !isRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// isRetryable reports serialization failures and deadlocks
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import "bufio"
+
+// Counter is a monotonically increasing value partitioned by labels
+type Counter struct {
+	vec
+}
+
+// NewCounter creates a counter and registers it with the DefaultRegistry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{newVec(name, help, labelNames)}
+	DefaultRegistry.Register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	c.Lock()
+	c.child(labelValues).value += v
+	c.Unlock()
+}
+
+func (c *Counter) Write(w *bufio.Writer) error {
+	c.Lock()
+	defer c.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	for _, ch := range c.sorted() {
+		writeSample(w, c.name, c.labelNames, ch.labelValues, ch.value)
+	}
+	return nil
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	vec
+}
+
+// NewGauge creates a gauge and registers it with the DefaultRegistry
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{newVec(name, help, labelNames)}
+	DefaultRegistry.Register(g)
+	return g
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.Lock()
+	g.child(labelValues).value = v
+	g.Unlock()
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.Lock()
+	g.child(labelValues).value += v
+	g.Unlock()
+}
+
+func (g *Gauge) Write(w *bufio.Writer) error {
+	g.Lock()
+	defer g.Unlock()
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, ch := range g.sorted() {
+		writeSample(w, g.name, g.labelNames, ch.labelValues, ch.value)
+	}
+	return nil
+}
+
+// GaugeFunc reads its samples at scrape time, collect calls emit once
+// per label combination.
+type GaugeFunc struct {
+	name       string
+	help       string
+	labelNames []string
+	collect    func(emit func(v float64, labelValues ...string))
+}
+
+// NewGaugeFunc creates a gauge func and registers it with the DefaultRegistry
+func NewGaugeFunc(name, help string, labelNames []string, collect func(emit func(v float64, labelValues ...string))) *GaugeFunc {
+	g := &GaugeFunc{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		collect:    collect,
+	}
+	DefaultRegistry.Register(g)
+	return g
+}
+
+func (g *GaugeFunc) Name() string {
+	return g.name
+}
+
+func (g *GaugeFunc) Write(w *bufio.Writer) error {
+	writeHeader(w, g.name, g.help, "gauge")
+	g.collect(func(v float64, labelValues ...string) {
+		writeSample(w, g.name, g.labelNames, labelValues, v)
+	})
+	return nil
+}
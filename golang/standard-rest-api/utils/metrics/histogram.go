@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bufio"
+	"sort"
+)
+
+// DefBuckets are tailored to request latencies in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	vec
+	upperBounds []float64
+}
+
+// NewHistogram creates a histogram and registers it with the DefaultRegistry,
+// DefBuckets are used when buckets is nil.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	upperBounds := append([]float64(nil), buckets...)
+	sort.Float64s(upperBounds)
+	h := &Histogram{
+		vec:         newVec(name, help, labelNames),
+		upperBounds: upperBounds,
+	}
+	DefaultRegistry.Register(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.Lock()
+	defer h.Unlock()
+	ch := h.child(labelValues)
+	if ch.buckets == nil {
+		ch.buckets = make([]uint64, len(h.upperBounds))
+	}
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	if i < len(ch.buckets) {
+		ch.buckets[i]++
+	}
+	ch.count++
+	ch.value += v
+}
+
+func (h *Histogram) Write(w *bufio.Writer) error {
+	h.Lock()
+	defer h.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	labelNames := append(append([]string(nil), h.labelNames...), "le")
+	for _, ch := range h.sorted() {
+		labelValues := append(append([]string(nil), ch.labelValues...), "")
+		var cumulative uint64
+		for i, upper := range h.upperBounds {
+			cumulative += ch.buckets[i]
+			labelValues[len(labelValues)-1] = formatFloat(upper)
+			writeSample(w, h.name+"_bucket", labelNames, labelValues, float64(cumulative))
+		}
+		labelValues[len(labelValues)-1] = "+Inf"
+		writeSample(w, h.name+"_bucket", labelNames, labelValues, float64(ch.count))
+		writeSample(w, h.name+"_sum", h.labelNames, ch.labelValues, ch.value)
+		writeSample(w, h.name+"_count", h.labelNames, ch.labelValues, float64(ch.count))
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector is a metric family that can write itself in the
+// Prometheus text exposition format.
+type Collector interface {
+	Name() string
+	Write(w *bufio.Writer) error
+}
+
+type Registry struct {
+	collectors map[string]Collector
+	sync.RWMutex
+}
+
+// DefaultRegistry is used by the package level constructors and Handler
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]Collector),
+	}
+}
+
+func (r *Registry) Register(c Collector) {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.collectors[c.Name()]; ok {
+		panic("metrics: Register called twice for " + c.Name())
+	}
+	r.collectors[c.Name()] = c
+}
+
+// WriteText writes every registered family sorted by name
+func (r *Registry) WriteText(w io.Writer) error {
+	r.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]Collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		if err := c.Write(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// Handler serves the default registry
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+// vec keeps one value per combination of label values
+type vec struct {
+	name       string
+	help       string
+	labelNames []string
+	children   map[string]*child
+	sync.Mutex
+}
+
+type child struct {
+	labelValues []string
+	value       float64
+	// histograms only
+	buckets []uint64
+	count   uint64
+}
+
+func newVec(name, help string, labelNames []string) vec {
+	return vec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		children:   make(map[string]*child),
+	}
+}
+
+func (v *vec) Name() string {
+	return v.name
+}
+
+// child must be called with the lock held
+func (v *vec) child(labelValues []string) *child {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	c, ok := v.children[key]
+	if !ok {
+		c = &child{labelValues: append([]string(nil), labelValues...)}
+		v.children[key] = c
+	}
+	return c
+}
+
+// sorted must be called with the lock held
+func (v *vec) sorted() []*child {
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	children := make([]*child, 0, len(keys))
+	for _, k := range keys {
+		children = append(children, v.children[k])
+	}
+	return children
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 {
+		w.WriteByte('{')
+		for i := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", labelNames[i], escapeLabel(labelValues[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"database/sql"
+	"sync"
+)
+
+var (
+	dbsMu   sync.Mutex
+	dbs     = make(map[string]*sql.DB)
+	dbNames []string
+)
+
+// RegisterDB exposes the sql.DB.Stats() pool gauges of db with the label db="name"
+func RegisterDB(name string, db *sql.DB) {
+	dbsMu.Lock()
+	defer dbsMu.Unlock()
+	if _, ok := dbs[name]; !ok {
+		dbNames = append(dbNames, name)
+	}
+	dbs[name] = db
+}
+
+func collectDBStats(value func(s sql.DBStats) float64) func(emit func(v float64, labelValues ...string)) {
+	return func(emit func(v float64, labelValues ...string)) {
+		dbsMu.Lock()
+		defer dbsMu.Unlock()
+		for _, name := range dbNames {
+			emit(value(dbs[name].Stats()), name)
+		}
+	}
+}
+
+func init() {
+	label := []string{"db"}
+	NewGaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.", label,
+		collectDBStats(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	NewGaugeFunc("db_open_connections", "Number of established connections, both in use and idle.", label,
+		collectDBStats(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	NewGaugeFunc("db_in_use_connections", "Number of connections currently in use.", label,
+		collectDBStats(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	NewGaugeFunc("db_idle_connections", "Number of idle connections.", label,
+		collectDBStats(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	NewGaugeFunc("db_wait_count", "Total number of connections waited for.", label,
+		collectDBStats(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	NewGaugeFunc("db_wait_duration_seconds", "Total time blocked waiting for a new connection.", label,
+		collectDBStats(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	NewGaugeFunc("db_max_idle_closed", "Total number of connections closed due to SetMaxIdleConns.", label,
+		collectDBStats(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	NewGaugeFunc("db_max_lifetime_closed", "Total number of connections closed due to SetConnMaxLifetime.", label,
+		collectDBStats(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// Codec encodes responses and decodes request bodies of one media type
+type Codec interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml"
+}
+
+// Encode wraps slices in a <response> element, encoding/xml would
+// otherwise write one root element per item.
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		root := xml.StartElement{Name: xml.Name{Local: "response"}}
+		if err := enc.EncodeToken(root); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(root.End()); err != nil {
+			return err
+		}
+		return enc.Flush()
+	}
+	return enc.Encode(v)
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+// Encode reuses the json tags so field names match the other formats
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	return msgpack.NewEncoder(w).UseJSONTag(true).Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	return msgpack.NewDecoder(r).UseJSONTag(true).Decode(v)
+}
+
+var (
+	JSON        Codec = jsonCodec{}
+	XML         Codec = xmlCodec{}
+	MessagePack Codec = msgpackCodec{}
+)
@@ -0,0 +1,172 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNotAcceptable        = errors.New("render: no acceptable media type")
+	ErrUnsupportedMediaType = errors.New("render: unsupported media type")
+)
+
+var (
+	mu sync.RWMutex
+	// codecs is ordered by preference, the first one is the default
+	codecs []Codec
+	// mediaTypes holds the media type and the aliases of each codec
+	mediaTypes [][]string
+	byType     = make(map[string]Codec)
+)
+
+// Register makes a codec available for negotiation, the media type may
+// have aliases, e.g. Register(MessagePack, "application/x-msgpack").
+func Register(c Codec, aliases ...string) {
+	if c == nil {
+		panic("render: Register codec is nil")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	types := append([]string{c.ContentType()}, aliases...)
+	for _, t := range types {
+		if _, ok := byType[t]; ok {
+			panic("render: Register called twice for media type " + t)
+		}
+		byType[t] = c
+	}
+	codecs = append(codecs, c)
+	mediaTypes = append(mediaTypes, types)
+}
+
+func init() {
+	Register(JSON)
+	Register(XML, "text/xml")
+	Register(MessagePack, "application/x-msgpack")
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	// Keep the client's order for equal weights
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// Negotiate picks the codec for the response from the Accept header
+func Negotiate(r *http.Request) (Codec, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return codecs[0], nil
+	}
+	ranges := parseAccept(header)
+	// A media type refused with q=0 isn't sent for a wildcard or an alias
+	// either, the more specific range wins
+	refused := make(map[string]bool)
+	for _, ar := range ranges {
+		if ar.q <= 0 {
+			refused[ar.mediaType] = true
+		}
+	}
+	for _, ar := range ranges {
+		if ar.q <= 0 {
+			continue
+		}
+		if c, ok := byType[ar.mediaType]; ok {
+			if refused[c.ContentType()] {
+				continue
+			}
+			return c, nil
+		}
+		if !strings.HasSuffix(ar.mediaType, "/*") {
+			continue
+		}
+		prefix := strings.TrimSuffix(ar.mediaType, "*")
+		for i, types := range mediaTypes {
+			if refused[codecs[i].ContentType()] {
+				continue
+			}
+			for _, t := range types {
+				if prefix == "*/" || strings.HasPrefix(t, prefix) {
+					return codecs[i], nil
+				}
+			}
+		}
+	}
+	return nil, ErrNotAcceptable
+}
+
+// Render writes v with the codec negotiated for r, or 406 Not Acceptable
+func Render(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	c, err := Negotiate(r)
+	if err != nil {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return err
+	}
+	w.Header().Set("Content-Type", c.ContentType())
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	return c.Encode(w, v)
+}
+
+// Marshal encodes v with the codec negotiated for r, for callers that need
+// the body before writing it, e.g. to hash it.
+func Marshal(r *http.Request, v interface{}) (Codec, []byte, error) {
+	c, err := Negotiate(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, v); err != nil {
+		return nil, nil, err
+	}
+	return c, buf.Bytes(), nil
+}
+
+// Bind decodes the request body into v with the codec of its Content-Type,
+// a missing Content-Type is decoded with the default codec.
+func Bind(r *http.Request, v interface{}) error {
+	mu.RLock()
+	c := codecs[0]
+	if header := r.Header.Get("Content-Type"); header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			mu.RUnlock()
+			return ErrUnsupportedMediaType
+		}
+		var ok bool
+		if c, ok = byType[mediaType]; !ok {
+			mu.RUnlock()
+			return ErrUnsupportedMediaType
+		}
+	}
+	mu.RUnlock()
+	return c.Decode(r.Body, v)
+}
@@ -0,0 +1,129 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string // empty when nothing is acceptable
+	}{
+		{"", "application/json"},
+		{"application/json", "application/json"},
+		{"application/xml", "application/xml"},
+		{"application/msgpack", "application/msgpack"},
+		// Aliases
+		{"text/xml", "application/xml"},
+		{"application/x-msgpack", "application/msgpack"},
+		// Weights, the client's order breaks ties
+		{"application/xml;q=0.5, application/json", "application/json"},
+		{"application/msgpack, application/xml", "application/msgpack"},
+		{"text/html, application/xml;q=0.1", "application/xml"},
+		// Wildcards follow the codec preference and match aliases
+		{"*/*", "application/json"},
+		{"application/*", "application/json"},
+		{"text/*", "application/xml"},
+		{"text/html, */*;q=0.1", "application/json"},
+		// q=0 refuses a type, wildcards don't bring it back
+		{"application/json;q=0, */*", "application/xml"},
+		{"application/json;q=0, application/*", "application/xml"},
+		{"application/json;q=0, application/xml;q=0, */*", "application/msgpack"},
+		{"application/xml;q=0, text/*", ""},
+		{"application/xml;q=0, text/xml", ""},
+		{"*/*;q=0", ""},
+		// Nothing registered
+		{"text/html", ""},
+		{"image/*", ""},
+		{"application/json;q=abc", ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		codec, err := Negotiate(r)
+		got := ""
+		if err == nil {
+			got = codec.ContentType()
+		} else if err != ErrNotAcceptable {
+			t.Errorf("Negotiate(%q) error == %v, want ErrNotAcceptable", c.accept, err)
+		}
+		if got != c.want {
+			t.Errorf("Negotiate(%q) == %q, want %q", c.accept, got, c.want)
+		}
+	}
+}
+
+type gopher struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestRender(t *testing.T) {
+	v := gopher{"gopher"}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+	if err := Render(w, r, http.StatusCreated, &v); err != nil {
+		t.Fatalf("Render error: %s", err)
+	}
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != "application/xml" {
+		t.Errorf("Render == %d %q, want 201 application/xml", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "<name>gopher</name>") {
+		t.Errorf("Render body == %q", w.Body.String())
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		t.Errorf("Vary == %q, want \"Accept\"", w.Header().Get("Vary"))
+	}
+
+	r.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	if err := Render(w, r, http.StatusOK, &v); err != ErrNotAcceptable {
+		t.Errorf("Render error == %v, want ErrNotAcceptable", err)
+	}
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Render status == %d, want 406", w.Code)
+	}
+}
+
+func TestBind(t *testing.T) {
+	type request struct {
+		Name string `json:"name" xml:"name"`
+	}
+	cases := []struct {
+		contentType, body string
+		want              string
+		err               error
+	}{
+		{"", `{"name":"gopher"}`, "gopher", nil},
+		{"application/json; charset=utf-8", `{"name":"gopher"}`, "gopher", nil},
+		{"application/xml", `<request><name>gopher</name></request>`, "gopher", nil},
+		{"text/xml", `<request><name>gopher</name></request>`, "gopher", nil},
+		{"text/plain", `gopher`, "", ErrUnsupportedMediaType},
+		{"not a media type", `{}`, "", ErrUnsupportedMediaType},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+		var got request
+		err := Bind(r, &got)
+		if err != c.err || got.Name != c.want {
+			t.Errorf("Bind(%q, %q) == %q, %v, want %q, %v", c.contentType, c.body, got.Name, err, c.want, c.err)
+		}
+	}
+
+	// A body that doesn't decode is an error of its own
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":`))
+	r.Header.Set("Content-Type", "application/json")
+	var got request
+	if err := Bind(r, &got); err == nil || err == ErrUnsupportedMediaType {
+		t.Errorf("Bind of a truncated body error == %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+// Package tracing records the spans of a request and of the work done for
+// it, such as its SQL queries. Finished spans go to the Exporter set with
+// SetExporter, nothing is recorded until one is set.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/standard-rest-api/logger"
+)
+
+// Span is one timed operation of a trace. A span is used by the goroutine
+// that started it, only End may be called more than once.
+type Span struct {
+	TraceID    string
+	ID         string
+	ParentID   string
+	Name       string
+	Start      time.Time
+	Duration   time.Duration
+	Attributes map[string]string
+	Err        error
+
+	exporter Exporter
+	ended    int32
+}
+
+// Exporter receives every span when it ends
+type Exporter interface {
+	ExportSpan(s *Span)
+}
+
+type ExporterFunc func(s *Span)
+
+func (f ExporterFunc) ExportSpan(s *Span) {
+	f(s)
+}
+
+type exporterBox struct {
+	Exporter
+}
+
+var exporter atomic.Value
+
+// SetExporter sends the spans ended from now on to e, nil stops tracing
+func SetExporter(e Exporter) {
+	exporter.Store(exporterBox{e})
+}
+
+type spanKey struct{}
+
+// FromContext returns the current span of ctx, nil when there is none
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start begins a span named name, child of the span of ctx. It returns a
+// nil span and ctx itself when no Exporter is set, the methods of a nil
+// span do nothing.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	box, _ := exporter.Load().(exporterBox)
+	if box.Exporter == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		ID:       newID(8),
+		Name:     name,
+		Start:    time.Now(),
+		exporter: box.Exporter,
+	}
+	if parent := FromContext(ctx); parent != nil {
+		s.TraceID, s.ParentID = parent.TraceID, parent.ID
+	} else {
+		s.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]string)
+	}
+	s.Attributes[key] = value
+}
+
+// End records the duration and the error of the span and exports it, only
+// the first call counts.
+func (s *Span) End(err error) {
+	if s == nil || !atomic.CompareAndSwapInt32(&s.ended, 0, 1) {
+		return
+	}
+	s.Duration = time.Since(s.Start)
+	s.Err = err
+	if s.exporter != nil {
+		s.exporter.ExportSpan(s)
+	}
+}
+
+// WithRemoteParent continues the trace of a W3C traceparent header such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", the spans
+// started from the returned context are children of the caller's span.
+// ctx is returned as is when the header is missing or malformed.
+func WithRemoteParent(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[0] != "00" || !isID(parts[1], 16) || !isID(parts[2], 8) {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, &Span{TraceID: parts[1], ID: parts[2]})
+}
+
+// isID reports a lowercase hex ID of n bytes that isn't all zeros
+func isID(s string, n int) bool {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != n || s != strings.ToLower(s) {
+		return false
+	}
+	for _, c := range b {
+		if c != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// LogExporter writes the spans with logger.Debug
+func LogExporter() Exporter {
+	return ExporterFunc(func(s *Span) {
+		keys := make([]string, 0, len(s.Attributes))
+		for k := range s.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := make([]string, len(keys))
+		for i, k := range keys {
+			attrs[i] = fmt.Sprintf("%s=%q", k, s.Attributes[k])
+		}
+		logger.Debug("span %s trace=%s id=%s parent=%s took %s error=%v %s",
+			s.Name, s.TraceID, s.ID, s.ParentID, s.Duration, s.Err, strings.Join(attrs, " "))
+	})
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// record sets an exporter collecting the spans for the rest of the test
+func record(t *testing.T) func() []*Span {
+	var (
+		mu    sync.Mutex
+		spans []*Span
+	)
+	SetExporter(ExporterFunc(func(s *Span) {
+		mu.Lock()
+		spans = append(spans, s)
+		mu.Unlock()
+	}))
+	t.Cleanup(func() { SetExporter(nil) })
+	return func() []*Span {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*Span(nil), spans...)
+	}
+}
+
+func TestStartWithoutExporter(t *testing.T) {
+	ctx := context.Background()
+	got, span := Start(ctx, "request")
+	if span != nil || got != ctx {
+		t.Fatalf("Start without an exporter == %v, %v, want ctx and a nil span", got, span)
+	}
+	// The nil span is usable
+	span.SetAttribute("key", "value")
+	span.End(nil)
+}
+
+func TestSpans(t *testing.T) {
+	spans := record(t)
+	ctx, root := Start(context.Background(), "request")
+	_, child := Start(ctx, "query")
+	child.SetAttribute("db.statement", "select 1")
+	failed := errors.New("failed")
+	child.End(failed)
+	child.End(nil)
+	root.End(nil)
+
+	got := spans()
+	if len(got) != 2 || got[0] != child || got[1] != root {
+		t.Fatalf("exported %v, want the child then the root, once each", got)
+	}
+	if len(root.TraceID) != 32 || len(root.ID) != 16 || root.ParentID != "" {
+		t.Errorf("root span == %+v", root)
+	}
+	if child.TraceID != root.TraceID || child.ParentID != root.ID || child.ID == root.ID {
+		t.Errorf("child span == %+v, want a child of %+v", child, root)
+	}
+	if child.Err != failed || child.Attributes["db.statement"] != "select 1" {
+		t.Errorf("child span == %+v, want the first End error and the attribute", child)
+	}
+	if FromContext(ctx) != root {
+		t.Error("FromContext didn't return the root span")
+	}
+}
+
+func TestWithRemoteParent(t *testing.T) {
+	record(t)
+	cases := []struct {
+		header string
+		valid  bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"", false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+	}
+	for _, c := range cases {
+		_, span := Start(WithRemoteParent(context.Background(), c.header), "request")
+		continued := span.TraceID == "4bf92f3577b34da6a3ce929d0e0e4736" && span.ParentID == "00f067aa0ba902b7"
+		if continued != c.valid {
+			t.Errorf("WithRemoteParent(%q) continued the trace == %v, want %v", c.header, continued, c.valid)
+		}
+		if !c.valid && span.ParentID != "" {
+			t.Errorf("WithRemoteParent(%q) gave the span the parent %s", c.header, span.ParentID)
+		}
+	}
+}
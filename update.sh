@@ -2,4 +2,7 @@
 
 go get golang.org/x/crypto/scrypt
 go get github.com/go-redis/redis
-go get github.com/lib/pq
\ No newline at end of file
+go get github.com/lib/pq
+go get github.com/vmihailenco/msgpack
+go get github.com/mattn/go-sqlite3
+go get gopkg.in/yaml.v2
\ No newline at end of file